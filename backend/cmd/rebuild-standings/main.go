@@ -0,0 +1,55 @@
+// Command rebuild-standings recomputes the materialized contest standings from
+// submissions. Run it from the backend directory so it picks up .env.
+//
+//	go run ./cmd/rebuild-standings              # every contest
+//	go run ./cmd/rebuild-standings -contest ID  # a single contest
+package main
+
+import (
+	"OJ-backend/config"
+	model "OJ-backend/models"
+	"OJ-backend/services/scoreboard"
+	"flag"
+	"log"
+)
+
+func main() {
+	contestID := flag.String("contest", "", "only rebuild the standings of this contest")
+	flag.Parse()
+
+	config.LoadEnv()
+
+	db, err := config.ConnectDB()
+	if err != nil {
+		log.Fatalf("Failed to connect to the database: %v", err)
+	}
+	if err := db.AutoMigrate(model.Standing{}); err != nil {
+		log.Fatalf("Failed to migrate standings table: %v", err)
+	}
+
+	var contests []model.Contest
+	query := db
+	if *contestID != "" {
+		query = query.Where("id = ?", *contestID)
+	}
+	if err := query.Find(&contests).Error; err != nil {
+		log.Fatalf("Failed to load contests: %v", err)
+	}
+	if len(contests) == 0 {
+		log.Fatalf("No contests found")
+	}
+
+	failed := 0
+	for _, contest := range contests {
+		if err := scoreboard.Rebuild(db, contest); err != nil {
+			log.Printf("Failed to rebuild standings of contest %s (%s): %v", contest.ID, contest.Name, err)
+			failed++
+			continue
+		}
+		log.Printf("Rebuilt standings of contest %s (%s)", contest.ID, contest.Name)
+	}
+
+	if failed > 0 {
+		log.Fatalf("%d of %d contests failed", failed, len(contests))
+	}
+}
@@ -6,6 +6,9 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"time"
+
 	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -29,6 +32,26 @@ func GetEnv(key string) string {
 	return val
 }
 
+// retrieve env value as a duration (e.g. "10m"), falling back when unset or invalid
+func GetEnvDuration(key string, fallback time.Duration) time.Duration {
+	val, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+
+	return val
+}
+
+// retrieve env value as an integer, falling back when unset or invalid
+func GetEnvInt(key string, fallback int) int {
+	val, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+
+	return val
+}
+
 var DB *gorm.DB
 
 // Connect to database
@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"OJ-backend/config"
+	models "OJ-backend/models"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	uuid "github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// accessDB returns a database that answers the queries behind contest and
+// problem access from memory: contests lists the contests that exist, all of
+// which are linked to the problem being looked up, and registered the users
+// registered for each contest
+func accessDB(t *testing.T, contests []models.Contest, registered map[uuid.UUID][]uuid.UUID) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The dry run builds each statement without running it; fill in its result
+	err = db.Callback().Query().After("gorm:query").Register("test:results", func(tx *gorm.DB) {
+		switch dest := tx.Statement.Dest.(type) {
+		case *[]models.Contest:
+			*dest = contests
+		case *models.Contest:
+			for _, contest := range contests {
+				if contest.ID.String() == fmt.Sprint(tx.Statement.Vars[0]) {
+					*dest = contest
+					return
+				}
+			}
+			tx.AddError(gorm.ErrRecordNotFound)
+		case *int64:
+			// isRegistered binds the contest and user IDs first
+			contestID, userID := tx.Statement.Vars[0].(uuid.UUID), tx.Statement.Vars[1].(uuid.UUID)
+			*dest = 0
+			for _, id := range registered[contestID] {
+				if id == userID {
+					*dest = 1
+				}
+			}
+			// Count reads the count from here unless exactly one row came back
+			tx.RowsAffected = 1
+		default:
+			t.Fatalf("unexpected query into %T", dest)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// contestIn returns a contest starting and ending the given durations from now
+func contestIn(start, end time.Duration, private bool) models.Contest {
+	now := time.Now()
+	return models.Contest{ID: uuid.New(), StartTime: now.Add(start), EndTime: now.Add(end), IsPrivate: private}
+}
+
+func TestContestOpenTo(t *testing.T) {
+	user := uuid.New()
+
+	tests := []struct {
+		name       string
+		contest    models.Contest
+		registered bool
+		want       bool
+	}{
+		{"public and running", contestIn(-time.Hour, time.Hour, false), false, true},
+		{"private and running for a participant", contestIn(-time.Hour, time.Hour, true), true, true},
+		{"private and running for anyone else", contestIn(-time.Hour, time.Hour, true), false, false},
+		{"private and ended", contestIn(-2*time.Hour, -time.Hour, true), false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registered := map[uuid.UUID][]uuid.UUID{}
+			if tt.registered {
+				registered[tt.contest.ID] = []uuid.UUID{user}
+			}
+
+			got, err := contestOpenTo(accessDB(t, nil, registered), tt.contest, user)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("contestOpenTo = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProblemAccess(t *testing.T) {
+	user := uuid.New()
+	upcoming := contestIn(time.Hour, 2*time.Hour, false)
+	running := contestIn(-time.Hour, time.Hour, false)
+	privateRunning := contestIn(-time.Hour, time.Hour, true)
+	registeredRunning := contestIn(-time.Hour, time.Hour, true)
+	ended := contestIn(-2*time.Hour, -time.Hour, true)
+	registered := map[uuid.UUID][]uuid.UUID{registeredRunning.ID: {user}}
+
+	tests := []struct {
+		name     string
+		public   bool
+		contests []models.Contest
+		visible  bool
+		practice bool
+	}{
+		{"public problem in no contest", true, nil, true, true},
+		{"private problem in no contest", false, nil, false, false},
+		{"public problem held back for an upcoming contest", true, []models.Contest{upcoming}, false, false},
+		{"problem of a running contest", false, []models.Contest{running}, true, false},
+		{"problem of a running private contest the user did not register for", false, []models.Contest{privateRunning}, false, false},
+		{"problem of a running private contest the user registered for", false, []models.Contest{registeredRunning}, true, false},
+		{"problem of an ended contest", false, []models.Contest{ended}, true, true},
+		{"problem of an ended contest reused in an upcoming one", false, []models.Contest{ended, upcoming}, true, false},
+		{"problem of an ended contest reused in a running one", true, []models.Contest{ended, privateRunning}, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problem := models.Problem{ID: uuid.New(), IsPublic: tt.public}
+
+			visible, practice, err := problemAccess(accessDB(t, tt.contests, registered), problem, user)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if visible != tt.visible || practice != tt.practice {
+				t.Errorf("problemAccess = (visible %v, practice %v), want (%v, %v)", visible, practice, tt.visible, tt.practice)
+			}
+		})
+	}
+}
+
+func TestLoadStartedContest(t *testing.T) {
+	user := uuid.New()
+	upcoming := contestIn(time.Hour, 2*time.Hour, false)
+	running := contestIn(-time.Hour, time.Hour, false)
+	privateRunning := contestIn(-time.Hour, time.Hour, true)
+	registeredRunning := contestIn(-time.Hour, time.Hour, true)
+	privateEnded := contestIn(-2*time.Hour, -time.Hour, true)
+	contests := []models.Contest{upcoming, running, privateRunning, registeredRunning, privateEnded}
+	registered := map[uuid.UUID][]uuid.UUID{registeredRunning.ID: {user}}
+
+	tests := []struct {
+		name      string
+		contestID string
+		status    int // Zero when the contest loads
+	}{
+		{"not started yet", upcoming.ID.String(), http.StatusForbidden},
+		{"running", running.ID.String(), 0},
+		{"running private contest without registering", privateRunning.ID.String(), http.StatusForbidden},
+		{"running private contest after registering", registeredRunning.ID.String(), 0},
+		{"ended private contest", privateEnded.ID.String(), 0},
+		{"unknown contest", uuid.NewString(), http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous := config.DB
+			config.DB = accessDB(t, contests, registered)
+			t.Cleanup(func() { config.DB = previous })
+
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+
+			contest, ok, err := loadStartedContest(c, tt.contestID, user)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.status == 0 {
+				if !ok || contest.ID.String() != tt.contestID {
+					t.Errorf("loadStartedContest refused the contest with %d: %s", rec.Code, rec.Body)
+				}
+				return
+			}
+			if ok || rec.Code != tt.status {
+				t.Errorf("loadStartedContest = (ok %v, status %d), want status %d", ok, rec.Code, tt.status)
+			}
+		})
+	}
+}
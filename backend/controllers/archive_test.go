@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"OJ-backend/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// recordQueries points config.DB at a database that builds statements without
+// running them and returns the SQL of every query, with its values inlined
+func recordQueries(t *testing.T) *[]string {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queries := []string{}
+	err = db.Callback().Query().After("gorm:query").Register("test:record", func(tx *gorm.DB) {
+		queries = append(queries, tx.Dialector.Explain(tx.Statement.SQL.String(), tx.Statement.Vars...))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := config.DB
+	config.DB = db
+	t.Cleanup(func() { config.DB = previous })
+	return &queries
+}
+
+func TestGetArchiveProblems(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		want   []string // Parts of the page query
+	}{
+		{
+			name:   "newest first by default",
+			status: http.StatusOK,
+			want:   []string{"contests.end_time >", "ORDER BY problems.created_at DESC, problems.id ASC", "LIMIT 20"},
+		},
+		{
+			name:   "search ranks by relevance",
+			query:  "q=shortest+path",
+			status: http.StatusOK,
+			want: []string{
+				"@@ websearch_to_tsquery('english', 'shortest path')",
+				"ORDER BY ts_rank(to_tsvector('english', title || ' ' || coalesce(description, '')), websearch_to_tsquery('english', 'shortest path')) DESC",
+			},
+		},
+		{
+			name:   "every tag must match",
+			query:  "tag=DP,%20graphs&tag=dp",
+			status: http.StatusOK,
+			want:   []string{"tags.name = 'dp'", "tags.name = 'graphs'"},
+		},
+		{
+			name:   "difficulty range",
+			query:  "difficulty_min=800&difficulty_max=1600",
+			status: http.StatusOK,
+			want:   []string{"problems.difficulty >= 800", "problems.difficulty <= 1600"},
+		},
+		{
+			name:   "sorted by solves ascending on a later page",
+			query:  "sort=solve_count&page=3&per_page=10",
+			status: http.StatusOK,
+			want:   []string{"submissions.result = 'AC') ASC, problems.id ASC", "LIMIT 10 OFFSET 20"},
+		},
+		{name: "relevance without a search", query: "sort=relevance", status: http.StatusBadRequest},
+		{name: "unknown sort", query: "sort=-author", status: http.StatusBadRequest},
+		{name: "invalid difficulty", query: "difficulty_min=hard", status: http.StatusBadRequest},
+		{name: "page out of range", query: "page=0", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries := recordQueries(t)
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/problems?"+tt.query, nil), rec)
+
+			if err := GetArchiveProblems(c); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			// The total is counted first, then the page is fetched
+			if len(*queries) < 2 {
+				t.Fatalf("ran %d queries, want the count and the page", len(*queries))
+			}
+			page := (*queries)[1]
+			for _, want := range tt.want {
+				if !strings.Contains(page, want) {
+					t.Errorf("page query %s\ndoes not contain %s", page, want)
+				}
+			}
+		})
+	}
+}
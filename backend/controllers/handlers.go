@@ -3,13 +3,21 @@ package handler
 import (
 	"OJ-backend/config"
 	models "OJ-backend/models"
+	"OJ-backend/services/outbox"
 	"OJ-backend/services/rabbitmq"
+	"OJ-backend/services/replay"
+	"OJ-backend/services/scoreboard"
 	"OJ-backend/services/sse"
+	"OJ-backend/services/webhook"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 	"io"
@@ -20,6 +28,7 @@ import (
 	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Claims struct {
@@ -37,6 +46,8 @@ func JWTMiddleware() echo.MiddlewareFunc {
 	return echojwt.WithConfig(echojwt.Config{
 		SigningKey: jwtSecret,
 		ContextKey: "user",
+		// EventSource cannot set headers, so SSE clients pass the token as a query parameter
+		TokenLookup: "header:Authorization:Bearer ,query:token",
 		NewClaimsFunc: func(c echo.Context) jwt.Claims {
 			return new(Claims)
 		},
@@ -53,6 +64,26 @@ func GetUserFromContext(c echo.Context) (username, email string) {
 	return claims.Username, claims.Email
 }
 
+// GetCurrentUser loads the user identified by the JWT claims
+func GetCurrentUser(c echo.Context) (models.User, error) {
+	_, email := GetUserFromContext(c)
+	var user models.User
+	err := config.DB.Where("email = ?", email).First(&user).Error
+	return user, err
+}
+
+// currentUserOrError loads the authenticated user, writing the error response if that fails
+func currentUserOrError(c echo.Context) (models.User, bool, error) {
+	user, err := GetCurrentUser(c)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return user, false, c.JSON(http.StatusUnauthorized, echo.Map{"error": "user not found"})
+		}
+		return user, false, c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+	}
+	return user, true, nil
+}
+
 func Login(c echo.Context) error {
 	var body struct {
 		Username string `json:"username"`
@@ -226,6 +257,8 @@ func AdminJWTMiddleware() echo.MiddlewareFunc {
 	return echojwt.WithConfig(echojwt.Config{
 		SigningKey: adminSecret,
 		ContextKey: "admin",
+		// EventSource cannot set headers, so SSE clients pass the token as a query parameter
+		TokenLookup: "header:Authorization:Bearer ,query:token",
 		NewClaimsFunc: func(c echo.Context) jwt.Claims {
 			return new(AdminClaims)
 		},
@@ -236,16 +269,168 @@ func AdminJWTMiddleware() echo.MiddlewareFunc {
 	})
 }
 
-// Get all contests
+// Report the health of the database and the RabbitMQ connection
+func HealthCheck(c echo.Context) error {
+	healthy := true
+
+	database := "up"
+	if sqlDB, err := config.DB.DB(); err != nil || sqlDB.PingContext(c.Request().Context()) != nil {
+		database = "down"
+		healthy = false
+	}
+
+	queue := rabbitmq.GetStatus()
+	if queue.State != rabbitmq.StateConnected {
+		healthy = false
+	}
+
+	status := http.StatusOK
+	if !healthy {
+		status = http.StatusServiceUnavailable
+	}
+
+	return c.JSON(status, echo.Map{
+		"healthy":  healthy,
+		"database": database,
+		"rabbitmq": queue,
+	})
+}
+
+// List contests with their status, filtered by the status query parameter
 func GetAllContests(c echo.Context) error {
+	return listContests(c, false)
+}
+
+// List contests with their status and invite codes
+func AdminGetAllContests(c echo.Context) error {
+	return listContests(c, true)
+}
+
+// contestSortColumns maps the sort query parameter to columns; prefix it with "-" to sort descending
+var contestSortColumns = map[string]string{
+	"start_time": "start_time",
+	"end_time":   "end_time",
+	"name":       "name",
+	"created_at": "created_at",
+}
+
+func listContests(c echo.Context, admin bool) error {
 	db := config.DB
-	var contests []models.Contest
+	now := time.Now()
+
+	page, perPage, err := parsePagination(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	query := db.Model(&models.Contest{})
+	status := c.QueryParam("status")
+	switch status {
+	case "":
+	case models.ContestUpcoming:
+		query = query.Where("start_time > ?", now)
+	case models.ContestRunning:
+		query = query.Where("start_time <= ? AND end_time > ?", now, now)
+	case models.ContestEnded:
+		query = query.Where("end_time <= ?", now)
+	default:
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid status"})
+	}
+
+	// Past contests read best most recent first, everything else soonest first
+	sort := c.QueryParam("sort")
+	if sort == "" {
+		sort = "start_time"
+		if status == models.ContestEnded {
+			sort = "-end_time"
+		}
+	}
+	column, ok := contestSortColumns[strings.TrimPrefix(sort, "-")]
+	if !ok {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid sort"})
+	}
+	order := column + " ASC"
+	if strings.HasPrefix(sort, "-") {
+		order = column + " DESC"
+	}
 
-	if err := db.Find(&contests).Error; err != nil {
+	// Start a new session so counting does not leak into the page query
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "failed to retrieve contests"})
+	}
+
+	var contests []models.Contest
+	if err := query.Order(order).Order("id ASC").Offset((page - 1) * perPage).Limit(perPage).Find(&contests).Error; err != nil {
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "failed to retrieve contests"})
 	}
 
-	return c.JSON(http.StatusOK, contests)
+	views := make([]models.ContestView, 0, len(contests))
+	for _, contest := range contests {
+		if !admin {
+			contest = contest.ForPublic()
+		}
+		views = append(views, models.ContestView{Contest: contest, Status: contest.Phase(now)})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"contests":    views,
+		"page":        page,
+		"per_page":    perPage,
+		"total":       total,
+		"server_time": now,
+	})
+}
+
+// Get a contest with the authenticated user's registration and progress on its problems
+func GetContestDetail(c echo.Context) error {
+	db := config.DB
+	now := time.Now()
+
+	user, ok, err := currentUserOrError(c)
+	if !ok {
+		return err
+	}
+
+	contest, ok, err := loadContest(c, c.Param("id"))
+	if !ok {
+		return err
+	}
+
+	detail := models.ContestDetail{
+		ContestView: models.ContestView{Contest: contest.ForPublic(), Status: contest.Phase(now)},
+		ServerTime:  now,
+		Problems:    []models.ContestProblemState{},
+	}
+
+	var registration models.ContestUser
+	if err := db.First(&registration, "contest_id = ? AND user_id = ?", contest.ID, user.ID).Error; err == nil {
+		detail.RegistrationStatus = registration.Status
+	} else if err != gorm.ErrRecordNotFound {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+	}
+
+	// Problems stay hidden until the contest starts, and private contests only
+	// show them to registered participants until they end
+	hidden := contest.IsPrivate && detail.Status != models.ContestEnded && detail.RegistrationStatus != models.ParticipantRegistered
+	if detail.Status != models.ContestUpcoming && !hidden {
+		if err := db.
+			Table("contest_problems").
+			Select("problems.id, contest_problems.label, problems.title, contest_problems.points, contest_problems.color, "+
+				"EXISTS (SELECT 1 FROM submissions WHERE submissions.problem_id = problems.id AND submissions.contest_id = contest_problems.contest_id AND submissions.user_id = ?) AS attempted, "+
+				"EXISTS (SELECT 1 FROM submissions WHERE submissions.problem_id = problems.id AND submissions.contest_id = contest_problems.contest_id AND submissions.user_id = ? AND submissions.result = ?) AS solved",
+				user.ID, user.ID, "AC").
+			Joins("JOIN problems ON contest_problems.problem_id = problems.id").
+			Where("contest_problems.contest_id = ?", contest.ID).
+			Order(models.ContestProblemOrder).
+			Scan(&detail.Problems).Error; err != nil {
+			return c.JSON(http.StatusInternalServerError, echo.Map{"error": "failed to retrieve problems"})
+		}
+	}
+
+	return c.JSON(http.StatusOK, detail)
 }
 
 // Create Contest
@@ -255,6 +440,16 @@ func CreateContest(c echo.Context) error {
 		Description string `json:"description"`
 		StartTime   string `json:"start_time"`
 		EndTime     string `json:"end_time"`
+
+		RegistrationDeadline string `json:"registration_deadline"`
+		MaxParticipants      int    `json:"max_participants"`
+		IsPrivate            bool   `json:"is_private"`
+		InviteCode           string `json:"invite_code"`
+		ScoringMode          string `json:"scoring_mode"`
+		FreezeMinutes        int    `json:"freeze_minutes"`
+		DecayPerMille        *int   `json:"decay_per_mille"`
+		FloorPercent         *int   `json:"floor_percent"`
+		WrongAttemptPenalty  *int   `json:"wrong_attempt_penalty"`
 	}
 
 	if err := c.Bind(&body); err != nil {
@@ -271,12 +466,46 @@ func CreateContest(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid end_time format"})
 	}
 
+	registrationDeadline, err := parseOptionalTime(body.RegistrationDeadline)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid registration_deadline format"})
+	}
+
+	if body.MaxParticipants < 0 {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "max_participants cannot be negative"})
+	}
+
+	if body.FreezeMinutes < 0 {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "freeze_minutes cannot be negative"})
+	}
+
+	if body.ScoringMode == "" {
+		body.ScoringMode = models.ScoringICPC
+	}
+	if !models.IsValidScoringMode(body.ScoringMode) {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid scoring_mode"})
+	}
+
 	contest := models.Contest{
 		ID:          uuid.New(),
 		Name:        body.Name,
 		Description: body.Description,
 		StartTime:   startTime,
 		EndTime:     endTime,
+
+		RegistrationDeadline: registrationDeadline,
+		MaxParticipants:      body.MaxParticipants,
+		IsPrivate:            body.IsPrivate,
+		InviteCode:           body.InviteCode,
+		ScoringMode:          body.ScoringMode,
+		FreezeMinutes:        body.FreezeMinutes,
+
+		DecayPerMille:       models.DefaultDecayPerMille,
+		FloorPercent:        models.DefaultFloorPercent,
+		WrongAttemptPenalty: models.DefaultWrongAttemptPenalty,
+	}
+	if err := setDynamicScoring(&contest, body.DecayPerMille, body.FloorPercent, body.WrongAttemptPenalty); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
 	}
 
 	db := config.DB
@@ -288,48 +517,150 @@ func CreateContest(c echo.Context) error {
 	return c.JSON(http.StatusCreated, contest)
 }
 
+// contestUpdate is the body of a contest update. Fields left out of the
+// request are nil and keep their current value.
+type contestUpdate struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	StartTime   *string `json:"start_time"`
+	EndTime     *string `json:"end_time"`
+
+	RegistrationDeadline *string `json:"registration_deadline"` // An empty string removes the deadline
+	MaxParticipants      *int    `json:"max_participants"`
+	IsPrivate            *bool   `json:"is_private"`
+	InviteCode           *string `json:"invite_code"`
+	ScoringMode          *string `json:"scoring_mode"`
+	FreezeMinutes        *int    `json:"freeze_minutes"`
+	DecayPerMille        *int    `json:"decay_per_mille"`
+	FloorPercent         *int    `json:"floor_percent"`
+	WrongAttemptPenalty  *int    `json:"wrong_attempt_penalty"`
+}
+
+// apply validates the fields that were given and copies them onto contest
+func (body contestUpdate) apply(contest *models.Contest) error {
+	if body.Name != nil {
+		contest.Name = *body.Name
+	}
+	if body.Description != nil {
+		contest.Description = *body.Description
+	}
+	if body.StartTime != nil {
+		startTime, err := time.Parse(time.RFC3339, *body.StartTime)
+		if err != nil {
+			return errors.New("invalid start_time format")
+		}
+		contest.StartTime = startTime
+	}
+	if body.EndTime != nil {
+		endTime, err := time.Parse(time.RFC3339, *body.EndTime)
+		if err != nil {
+			return errors.New("invalid end_time format")
+		}
+		contest.EndTime = endTime
+	}
+	if body.RegistrationDeadline != nil {
+		registrationDeadline, err := parseOptionalTime(*body.RegistrationDeadline)
+		if err != nil {
+			return errors.New("invalid registration_deadline format")
+		}
+		contest.RegistrationDeadline = registrationDeadline
+	}
+	if body.MaxParticipants != nil {
+		if *body.MaxParticipants < 0 {
+			return errors.New("max_participants cannot be negative")
+		}
+		contest.MaxParticipants = *body.MaxParticipants
+	}
+	if body.IsPrivate != nil {
+		contest.IsPrivate = *body.IsPrivate
+	}
+	if body.InviteCode != nil {
+		contest.InviteCode = *body.InviteCode
+	}
+	if body.ScoringMode != nil {
+		if !models.IsValidScoringMode(*body.ScoringMode) {
+			return errors.New("invalid scoring_mode")
+		}
+		contest.ScoringMode = *body.ScoringMode
+	}
+	if body.FreezeMinutes != nil {
+		if *body.FreezeMinutes < 0 {
+			return errors.New("freeze_minutes cannot be negative")
+		}
+		contest.FreezeMinutes = *body.FreezeMinutes
+	}
+
+	return setDynamicScoring(contest, body.DecayPerMille, body.FloorPercent, body.WrongAttemptPenalty)
+}
+
+// Update a contest, changing only the fields given in the request
 func UpdateContest(c echo.Context) error {
 	contestID := c.Param("id")
 	db := config.DB
-	var body struct {
-		Name        string `json:"name"`
-		Description string `json:"description"`
-		StartTime   string `json:"start_time"`
-		EndTime     string `json:"end_time"`
-	}
+	var body contestUpdate
 
 	if err := c.Bind(&body); err != nil {
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
 	}
 
-	startTime, err := time.Parse(time.RFC3339, body.StartTime)
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid start_time format"})
-	}
-
-	endTime, err := time.Parse(time.RFC3339, body.EndTime)
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid end_time format"})
-	}
-
 	var contest models.Contest
 
 	if err := db.First(&contest, "id = ?", contestID).Error; err != nil {
 		return c.JSON(http.StatusNotFound, echo.Map{"error": "contest not found"})
 	}
 
-	contest.Name = body.Name
-	contest.Description = body.Description
-	contest.StartTime = startTime
-	contest.EndTime = endTime
+	if err := body.apply(&contest); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
 
 	if err := db.Save(&contest).Error; err != nil {
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "could not update contest"})
 	}
 
+	// Penalties and the frozen view depend on the contest's times and scoring mode
+	if err := scoreboard.Rebuild(db, contest); err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "contest updated but standings could not be rebuilt"})
+	}
+	scoreboard.Publish(db, contest.ID)
+
 	return c.JSON(http.StatusOK, contest)
 }
 
+// parseOptionalTime parses an RFC3339 time, treating an empty string as unset
+func parseOptionalTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// setDynamicScoring validates and applies the dynamic scoring settings that were given
+func setDynamicScoring(contest *models.Contest, decayPerMille, floorPercent, wrongAttemptPenalty *int) error {
+	if decayPerMille != nil {
+		if *decayPerMille < 0 || *decayPerMille > 1000 {
+			return errors.New("decay_per_mille must be between 0 and 1000")
+		}
+		contest.DecayPerMille = *decayPerMille
+	}
+	if floorPercent != nil {
+		if *floorPercent < 0 || *floorPercent > 100 {
+			return errors.New("floor_percent must be between 0 and 100")
+		}
+		contest.FloorPercent = *floorPercent
+	}
+	if wrongAttemptPenalty != nil {
+		if *wrongAttemptPenalty < 0 {
+			return errors.New("wrong_attempt_penalty cannot be negative")
+		}
+		contest.WrongAttemptPenalty = *wrongAttemptPenalty
+	}
+	return nil
+}
+
 // Delete Contest
 func DeleteContest(c echo.Context) error {
 	contestID := c.Param("id")
@@ -344,404 +675,1871 @@ func DeleteContest(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
 	}
 
-	// Delete the contest
-	if err := db.Delete(&contest).Error; err != nil {
+	// Delete the contest with its participants and standings, leaving its
+	// problems in the archive
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&models.ContestProblem{}, &models.ContestUser{}, &models.Standing{}} {
+			if err := tx.Where("contest_id = ?", contest.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&contest).Error
+	})
+	if err != nil {
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "could not delete contest"})
 	}
 
+	scoreboard.Invalidate(contest.ID)
+
 	return c.JSON(http.StatusOK, echo.Map{"message": "contest deleted successfully"})
 }
 
-// Get Problems by Contest ID
-func GetAllProblemsByContestID(c echo.Context) error {
-	contestID := c.Param("id")
+// Register the authenticated user for a contest. Private contests admit users
+// with the invite code straight away and record anyone else as a pending
+// request for an admin to approve.
+func RegisterForContest(c echo.Context) error {
 	db := config.DB
-	var problems []models.Problem
+	var body struct {
+		InviteCode string `json:"invite_code"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	}
 
-	if err := db.Preload("Tests").Where("contest_id = ?", contestID).Find(&problems).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "failed to retrieve problems"})
+	user, ok, err := currentUserOrError(c)
+	if !ok {
+		return err
 	}
 
-	if len(problems) == 0 {
-		return c.JSON(http.StatusNotFound, echo.Map{"error": "no problems found for this contest"})
+	contest, ok, err := loadContest(c, c.Param("id"))
+	if !ok {
+		return err
 	}
 
-	return c.JSON(http.StatusOK, problems)
+	if !contest.RegistrationOpen(time.Now()) {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": "registration is closed"})
+	}
+
+	status := models.ParticipantRegistered
+	if contest.IsPrivate {
+		switch {
+		case body.InviteCode == "":
+			status = models.ParticipantPending
+		case contest.InviteCode == "" || !hmac.Equal([]byte(body.InviteCode), []byte(contest.InviteCode)):
+			return c.JSON(http.StatusForbidden, echo.Map{"error": "invalid invite code"})
+		}
+	}
+
+	var registration models.ContestUser
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Lock the contest so concurrent registrations cannot exceed its capacity
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&contest, "id = ?", contest.ID).Error; err != nil {
+			return err
+		}
+
+		err := tx.First(&registration, "contest_id = ? AND user_id = ?", contest.ID, user.ID).Error
+		if err == nil {
+			// Only a pending request can be upgraded by presenting the invite code
+			if registration.Status != models.ParticipantPending || status != models.ParticipantRegistered {
+				return nil
+			}
+		} else if err != gorm.ErrRecordNotFound {
+			return err
+		}
+
+		if status == models.ParticipantRegistered && contest.MaxParticipants > 0 {
+			count, err := countParticipants(tx, contest.ID)
+			if err != nil {
+				return err
+			}
+			if count >= int64(contest.MaxParticipants) {
+				return errContestFull
+			}
+		}
+
+		registration.ContestID = contest.ID
+		registration.UserID = user.ID
+		registration.Status = status
+		return tx.Save(&registration).Error
+	})
+	if err == errContestFull {
+		return c.JSON(http.StatusConflict, echo.Map{"error": "contest is full"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "could not register for contest"})
+	}
+
+	if registration.Status == models.ParticipantDisqualified {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": "you have been disqualified from this contest"})
+	}
+
+	return c.JSON(http.StatusOK, registration)
 }
 
-// Get Problem by ID
-func GetProblemByID(c echo.Context) error {
-	problemID := c.Param("id")
+// errContestFull is returned when a contest has reached its participant limit
+var errContestFull = errors.New("contest is full")
+
+// countParticipants counts the registered participants of a contest
+func countParticipants(db *gorm.DB, contestID uuid.UUID) (int64, error) {
+	var count int64
+	err := db.Model(&models.ContestUser{}).
+		Where("contest_id = ? AND status = ?", contestID, models.ParticipantRegistered).
+		Count(&count).Error
+	return count, err
+}
+
+// Leave a contest, or withdraw a pending request to join it
+func LeaveContest(c echo.Context) error {
 	db := config.DB
-	var problem models.Problem
 
-	if err := db.Preload("Tests").First(&problem, "id = ?", problemID).Error; err != nil {
+	user, ok, err := currentUserOrError(c)
+	if !ok {
+		return err
+	}
+
+	contest, ok, err := loadContest(c, c.Param("id"))
+	if !ok {
+		return err
+	}
+
+	var registration models.ContestUser
+	if err := db.First(&registration, "contest_id = ? AND user_id = ?", contest.ID, user.ID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return c.JSON(http.StatusNotFound, echo.Map{"error": "problem not found"})
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "not registered for this contest"})
 		}
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
 	}
 
-	return c.JSON(http.StatusOK, problem)
-}
-
-// Create Problems in a Contest
-func CreateProblem(c echo.Context) error {
-	contestID := c.Param("id")
-	var body struct {
-		Title       string `json:"title"`
-		Description string `json:"description"`
+	// Disqualifications stick, and participants who may already have ranked
+	// submissions cannot drop out of the standings
+	switch {
+	case registration.Status == models.ParticipantDisqualified:
+		return c.JSON(http.StatusForbidden, echo.Map{"error": "you have been disqualified from this contest"})
+	case registration.Status == models.ParticipantRegistered && contest.Phase(time.Now()) != models.ContestUpcoming:
+		return c.JSON(http.StatusForbidden, echo.Map{"error": "cannot leave a contest that has started"})
 	}
 
-	if err := c.Bind(&body); err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	if err := db.Delete(&registration).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "could not leave contest"})
 	}
 
+	return c.JSON(http.StatusOK, echo.Map{"message": "left contest"})
+}
+
+// List the participants of a contest, optionally filtered by status
+func GetContestParticipants(c echo.Context) error {
 	db := config.DB
-	var contest models.Contest
 
-	if err := db.First(&contest, "id = ?", contestID).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return c.JSON(http.StatusNotFound, echo.Map{"error": "contest not found"})
-		}
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+	contest, ok, err := loadContest(c, c.Param("id"))
+	if !ok {
+		return err
 	}
 
-	problem := models.Problem{
-		ID:          uuid.New(),
-		ContestID:   contest.ID,
-		Title:       body.Title,
-		Description: body.Description,
+	query := db.
+		Table("contest_users").
+		Select("contest_users.user_id, users.username, users.email, contest_users.status, contest_users.registered_at").
+		Joins("JOIN users ON contest_users.user_id = users.id").
+		Where("contest_users.contest_id = ?", contest.ID)
+	if status := c.QueryParam("status"); status != "" {
+		query = query.Where("contest_users.status = ?", status)
 	}
 
-	if err := db.Create(&problem).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "could not create problem"})
+	participants := []models.ParticipantEntry{}
+	if err := query.Order("contest_users.registered_at ASC").Scan(&participants).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "failed to retrieve participants"})
 	}
 
-	return c.JSON(http.StatusCreated, problem)
+	return c.JSON(http.StatusOK, participants)
 }
 
-// Update Problem in a Contest
-func UpdateProblem(c echo.Context) error {
-	problemID := c.Param("id")
+// Add a user to a contest as a registered participant, bypassing the
+// registration deadline, capacity and invite code. Users who are already
+// participants, including pending or disqualified ones, are left as they are.
+func AddContestParticipant(c echo.Context) error {
 	db := config.DB
 	var body struct {
-		Title       string `json:"title"`
-		Description string `json:"description"`
+		UserID string `json:"user_id"`
+		Email  string `json:"email"`
 	}
 	if err := c.Bind(&body); err != nil {
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
 	}
-	var problem models.Problem
-	if err := db.First(&problem, "id = ?", problemID).Error; err != nil {
+
+	contest, ok, err := loadContest(c, c.Param("id"))
+	if !ok {
+		return err
+	}
+
+	var user models.User
+	query := db
+	switch {
+	case body.UserID != "":
+		query = query.Where("id = ?", body.UserID)
+	case body.Email != "":
+		query = query.Where("email = ?", body.Email)
+	default:
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "user_id or email is required"})
+	}
+	if err := query.First(&user).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return c.JSON(http.StatusNotFound, echo.Map{"error": "problem not found"})
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "user not found"})
 		}
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
 	}
-	problem.Title = body.Title
-	problem.Description = body.Description
-	if err := db.Save(&problem).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "could not update problem"})
+
+	// Changing an existing participant's status goes through UpdateContestParticipant
+	var existing int64
+	if err := db.Model(&models.ContestUser{}).Where("contest_id = ? AND user_id = ?", contest.ID, user.ID).Count(&existing).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
 	}
-	return c.JSON(http.StatusOK, problem)
+	if existing > 0 {
+		return c.JSON(http.StatusConflict, echo.Map{"error": "user is already a participant of this contest"})
+	}
+
+	registration := models.ContestUser{
+		ContestID: contest.ID,
+		UserID:    user.ID,
+		Status:    models.ParticipantRegistered,
+	}
+	if err := db.Create(&registration).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "could not add participant"})
+	}
+
+	scoreboard.Publish(db, contest.ID)
+
+	return c.JSON(http.StatusOK, registration)
 }
 
-// Delete problem in a contest
-func DeleteProblem(c echo.Context) error {
-	problemID := c.Param("id")
+// Change a participant's status, e.g. to approve a pending request or to disqualify them
+func UpdateContestParticipant(c echo.Context) error {
 	db := config.DB
+	var body struct {
+		Status string `json:"status"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	}
+	if !models.IsValidParticipantStatus(body.Status) {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid status"})
+	}
 
-	// Check if the problem exists
-	var problem models.Problem
-	if err := db.First(&problem, "id = ?", problemID).Error; err != nil {
+	var registration models.ContestUser
+	if err := db.First(&registration, "contest_id = ? AND user_id = ?", c.Param("id"), c.Param("user_id")).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return c.JSON(http.StatusNotFound, echo.Map{"error": "problem not found"})
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "participant not found"})
 		}
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
 	}
 
-	// Delete the problem
-	if err := db.Delete(&problem).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "could not delete problem"})
+	registration.Status = body.Status
+	if err := db.Save(&registration).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "could not update participant"})
 	}
 
-	return c.JSON(http.StatusOK, echo.Map{"message": "problem deleted successfully"})
+	scoreboard.Publish(db, registration.ContestID)
+
+	return c.JSON(http.StatusOK, registration)
 }
 
-// Get all test cases for a problem
-func GetAllTestCasesByProblemID(c echo.Context) error {
-	problemID := c.Param("id")
+// Remove a participant from a contest
+func RemoveContestParticipant(c echo.Context) error {
 	db := config.DB
-	var testCases []models.TestCase
 
-	if err := db.Where("problem_id = ?", problemID).Find(&testCases).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "failed to retrieve test cases"})
+	contest, ok, err := loadContest(c, c.Param("id"))
+	if !ok {
+		return err
 	}
 
-	if len(testCases) == 0 {
-		return c.JSON(http.StatusNotFound, echo.Map{"error": "no test cases found for this problem"})
+	result := db.Where("contest_id = ? AND user_id = ?", contest.ID, c.Param("user_id")).Delete(&models.ContestUser{})
+	if result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "could not remove participant"})
+	}
+	if result.RowsAffected == 0 {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "participant not found"})
 	}
 
-	return c.JSON(http.StatusOK, testCases)
+	scoreboard.Publish(db, contest.ID)
+
+	return c.JSON(http.StatusOK, echo.Map{"message": "participant removed successfully"})
 }
 
-// Create Testcase for a problem
-func CreateTestCase(c echo.Context) error {
-	problemID := c.Param("id")
-	var body struct {
-		Input  string `json:"input"`
-		Output string `json:"output"`
+// loadContest loads a contest, writing the error response if it cannot
+func loadContest(c echo.Context, contestID interface{}) (models.Contest, bool, error) {
+	var contest models.Contest
+
+	if err := config.DB.First(&contest, "id = ?", contestID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return contest, false, c.JSON(http.StatusNotFound, echo.Map{"error": "contest not found"})
+		}
+		return contest, false, c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
 	}
 
-	if err := c.Bind(&body); err != nil {
+	return contest, true, nil
+}
+
+// loadStartedContest loads a contest for a contestant, who may not see it
+// until it starts, nor see a private contest they are not registered for
+func loadStartedContest(c echo.Context, contestID interface{}, userID uuid.UUID) (models.Contest, bool, error) {
+	contest, ok, err := loadContest(c, contestID)
+	if !ok {
+		return contest, false, err
+	}
+
+	if contest.Phase(time.Now()) == models.ContestUpcoming {
+		return contest, false, c.JSON(http.StatusForbidden, echo.Map{"error": "contest has not started yet"})
+	}
+
+	open, err := contestOpenTo(config.DB, contest, userID)
+	if err != nil {
+		return contest, false, c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+	}
+	if !open {
+		return contest, false, c.JSON(http.StatusForbidden, echo.Map{"error": "register for the contest to see its problems"})
+	}
+
+	return contest, true, nil
+}
+
+// contestOpenTo reports whether a user may see a contest's problems. Private
+// contests keep them to registered participants until the contest ends, when
+// they become practice problems like those of any other contest.
+func contestOpenTo(db *gorm.DB, contest models.Contest, userID uuid.UUID) (bool, error) {
+	if !contest.IsPrivate || contest.Phase(time.Now()) == models.ContestEnded {
+		return true, nil
+	}
+	return isRegistered(db, contest.ID, userID)
+}
+
+// isRegistered reports whether a user is a registered participant of a contest
+// who has not been disqualified
+func isRegistered(db *gorm.DB, contestID, userID uuid.UUID) (bool, error) {
+	var count int64
+	if err := db.Model(&models.ContestUser{}).
+		Where("contest_id = ? AND user_id = ? AND status = ?", contestID, userID, models.ParticipantRegistered).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// Get Problems by Contest ID along with their sample test cases
+func GetAllProblemsByContestID(c echo.Context) error {
+	user, ok, err := currentUserOrError(c)
+	if !ok {
+		return err
+	}
+
+	if _, ok, err := loadStartedContest(c, c.Param("id"), user.ID); !ok {
+		return err
+	}
+
+	return getProblemsByContestID(c, true)
+}
+
+// Get Problems by Contest ID along with all of their test cases
+func AdminGetAllProblemsByContestID(c echo.Context) error {
+	return getProblemsByContestID(c, false)
+}
+
+func getProblemsByContestID(c echo.Context, samplesOnly bool) error {
+	contestID := c.Param("id")
+	db := config.DB
+	var links []models.ContestProblem
+
+	if err := db.
+		Preload("Problem").
+		Preload("Problem.Tests", testCaseScope(samplesOnly)).
+		Where("contest_id = ?", contestID).
+		Order(models.ContestProblemOrder).
+		Find(&links).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "failed to retrieve problems"})
+	}
+
+	if len(links) == 0 {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "no problems found for this contest"})
+	}
+
+	problems := make([]models.ContestProblemView, 0, len(links))
+	for _, link := range links {
+		problems = append(problems, link.View())
+	}
+
+	return c.JSON(http.StatusOK, problems)
+}
+
+// testCaseScope limits a test case query to samples for contestant-facing endpoints
+func testCaseScope(samplesOnly bool) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if samplesOnly {
+			return db.Where("is_sample = ?", true)
+		}
+		return db
+	}
+}
+
+// Get Problem by ID along with its sample test cases
+func GetProblemByID(c echo.Context) error {
+	problemID := c.Param("id")
+	db := config.DB
+	var problem models.Problem
+
+	user, ok, err := currentUserOrError(c)
+	if !ok {
+		return err
+	}
+
+	if err := db.Preload("Tests", testCaseScope(true)).First(&problem, "id = ?", problemID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "problem not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+	}
+
+	visible, practice, err := problemAccess(db, problem, user.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+	}
+	if !visible {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": "problem is not available yet"})
+	}
+
+	// Tags would give hints away while the problem is still in a running contest
+	if practice {
+		if err := db.Model(&problem).Order("name ASC").Association("Tags").Find(&problem.Tags); err != nil {
+			return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+		}
+	}
+
+	return c.JSON(http.StatusOK, problem)
+}
+
+// problemAccess reports whether a contestant may see a problem and whether they
+// may practise it. A problem shows up once one of its contests starts, or right
+// away when it is public and not held back for an upcoming contest. A running
+// private contest only shows it to its registered participants. It can be
+// practised once it has been shown and none of its contests is still running.
+func problemAccess(db *gorm.DB, problem models.Problem, userID uuid.UUID) (visible, practice bool, err error) {
+	var contests []models.Contest
+	if err := db.
+		Joins("JOIN contest_problems ON contest_problems.contest_id = contests.id").
+		Where("contest_problems.problem_id = ?", problem.ID).
+		Find(&contests).Error; err != nil {
+		return false, false, err
+	}
+
+	now := time.Now()
+	started, open := false, false
+	for _, contest := range contests {
+		switch contest.Phase(now) {
+		case models.ContestUpcoming:
+			open = true
+		case models.ContestRunning:
+			shown, err := contestOpenTo(db, contest, userID)
+			if err != nil {
+				return false, false, err
+			}
+			started, open = started || shown, true
+		default:
+			started = true
+		}
+	}
+
+	visible = started || (problem.IsPublic && !open)
+	practice = visible && !open
+	return visible, practice, nil
+}
+
+// List the archive: problems that can be practised outside a running contest
+func GetArchiveProblems(c echo.Context) error {
+	return listProblems(c, true)
+}
+
+// List every problem with the contests it belongs to
+func AdminGetAllProblems(c echo.Context) error {
+	return listProblems(c, false)
+}
+
+// problemSortColumns maps the sort query parameter of problem lists to
+// columns; prefix it with "-" to sort descending
+var problemSortColumns = map[string]string{
+	"created_at":  "problems.created_at",
+	"title":       "problems.title",
+	"difficulty":  "problems.difficulty",
+	"solve_count": "(SELECT COUNT(DISTINCT submissions.user_id) FROM submissions WHERE submissions.problem_id = problems.id AND submissions.result = 'AC')",
+}
+
+// listProblems lists problems filtered by the q full-text query, the tag
+// parameter (repeated or comma separated, all must match) and the
+// difficulty_min and difficulty_max parameters
+func listProblems(c echo.Context, archiveOnly bool) error {
+	db := config.DB
+
+	page, perPage, err := parsePagination(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	query := db.Model(&models.Problem{})
+	if archiveOnly {
+		// Matches problemAccess: shown, and not in a contest that is still upcoming or running
+		query = query.Where("problems.is_public OR EXISTS (SELECT 1 FROM contest_problems WHERE contest_problems.problem_id = problems.id)").
+			Where("NOT EXISTS (SELECT 1 FROM contest_problems JOIN contests ON contest_problems.contest_id = contests.id WHERE contest_problems.problem_id = problems.id AND contests.end_time > ?)", time.Now())
+	}
+
+	search := strings.TrimSpace(c.QueryParam("q"))
+	if search != "" {
+		query = query.Where(models.ProblemSearchVector+" @@ websearch_to_tsquery('english', ?)", search)
+	}
+
+	var tags []string
+	for _, value := range c.QueryParams()["tag"] {
+		tags = append(tags, strings.Split(value, ",")...)
+	}
+	for _, tag := range models.NormalizeTags(tags) {
+		query = query.Where("EXISTS (SELECT 1 FROM problem_tags JOIN tags ON problem_tags.tag_id = tags.id WHERE problem_tags.problem_id = problems.id AND tags.name = ?)", tag)
+	}
+
+	for param, condition := range map[string]string{"difficulty_min": "problems.difficulty >= ?", "difficulty_max": "problems.difficulty <= ?"} {
+		value := c.QueryParam(param)
+		if value == "" {
+			continue
+		}
+		difficulty, err := strconv.Atoi(value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid " + param})
+		}
+		query = query.Where(condition, difficulty)
+	}
+
+	// Searches read best most relevant first, everything else newest first
+	sort := c.QueryParam("sort")
+	if sort == "" {
+		sort = "-created_at"
+		if search != "" {
+			sort = "relevance"
+		}
+	}
+	// The whole ordering is one expression, as the relevance rank needs the query as a parameter
+	var order clause.Expr
+	if sort == "relevance" {
+		if search == "" {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "sorting by relevance needs q"})
+		}
+		order = clause.Expr{
+			SQL:  "ts_rank(" + models.ProblemSearchVector + ", websearch_to_tsquery('english', ?)) DESC, problems.id ASC",
+			Vars: []interface{}{search},
+		}
+	} else {
+		column, ok := problemSortColumns[strings.TrimPrefix(sort, "-")]
+		if !ok {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid sort"})
+		}
+		direction := " ASC"
+		if strings.HasPrefix(sort, "-") {
+			direction = " DESC"
+		}
+		order = clause.Expr{SQL: column + direction + ", problems.id ASC"}
+	}
+
+	// Start a new session so counting does not leak into the page query
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "failed to retrieve problems"})
+	}
+
+	pageQuery := query.Preload("Tags", func(db *gorm.DB) *gorm.DB { return db.Order("name ASC") })
+	if !archiveOnly {
+		pageQuery = pageQuery.Preload("Contests")
+	}
+
+	var problems []models.Problem
+	if err := pageQuery.Order(clause.OrderBy{Expression: order}).Offset((page - 1) * perPage).Limit(perPage).Find(&problems).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "failed to retrieve problems"})
+	}
+
+	solveCounts, err := countSolves(db, problems)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "failed to retrieve problems"})
+	}
+
+	summaries := make([]models.ProblemSummary, 0, len(problems))
+	for _, problem := range problems {
+		summaries = append(summaries, models.ProblemSummary{Problem: problem, SolveCount: solveCounts[problem.ID]})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"problems": summaries,
+		"page":     page,
+		"per_page": perPage,
+		"total":    total,
+	})
+}
+
+// countSolves counts the users with an accepted submission on each of the problems
+func countSolves(db *gorm.DB, problems []models.Problem) (map[uuid.UUID]int64, error) {
+	counts := make(map[uuid.UUID]int64, len(problems))
+	if len(problems) == 0 {
+		return counts, nil
+	}
+
+	problemIDs := make([]uuid.UUID, 0, len(problems))
+	for _, problem := range problems {
+		problemIDs = append(problemIDs, problem.ID)
+	}
+
+	var rows []struct {
+		ProblemID  uuid.UUID
+		SolveCount int64
+	}
+	if err := db.
+		Table("submissions").
+		Select("problem_id, COUNT(DISTINCT user_id) AS solve_count").
+		Where("problem_id IN ? AND result = ?", problemIDs, "AC").
+		Group("problem_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		counts[row.ProblemID] = row.SolveCount
+	}
+	return counts, nil
+}
+
+// List every tag, for filtering the archive
+func GetTags(c echo.Context) error {
+	tags := []models.Tag{}
+	if err := config.DB.Order("name ASC").Find(&tags).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "failed to retrieve tags"})
+	}
+
+	return c.JSON(http.StatusOK, tags)
+}
+
+// Create a problem, either in the archive or, when a contest ID is given, linked to that contest
+func CreateProblem(c echo.Context) error {
+	contestID := c.Param("id")
+	var body struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		IsPublic    bool     `json:"is_public"`
+		Difficulty  int      `json:"difficulty"`
+		Source      string   `json:"source"`
+		Tags        []string `json:"tags"`
+		Label       string   `json:"label"`
+		Points      *int     `json:"points"`
+		Color       string   `json:"color"`
+	}
+
+	if err := c.Bind(&body); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	}
+
+	if body.Difficulty < 0 {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "difficulty cannot be negative"})
+	}
+
+	db := config.DB
+
+	problem := models.Problem{
+		ID:          uuid.New(),
+		Title:       body.Title,
+		Description: body.Description,
+		IsPublic:    body.IsPublic,
+		Difficulty:  body.Difficulty,
+		Source:      body.Source,
+	}
+
+	if contestID == "" {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&problem).Error; err != nil {
+				return err
+			}
+			return setProblemTags(tx, &problem, body.Tags)
+		})
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, echo.Map{"error": "could not create problem"})
+		}
+		return c.JSON(http.StatusCreated, problem)
+	}
+
+	contest, ok, err := loadContest(c, contestID)
+	if !ok {
+		return err
+	}
+
+	link := models.ContestProblem{ContestID: contest.ID, ProblemID: problem.ID, Label: strings.TrimSpace(body.Label), Points: models.DefaultProblemPoints, Color: body.Color}
+	if body.Points != nil {
+		if *body.Points <= 0 {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "points must be positive"})
+		}
+		link.Points = *body.Points
+	}
+	if !models.IsValidColor(link.Color) {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "color must be #rrggbb"})
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&problem).Error; err != nil {
+			return err
+		}
+		if err := setProblemTags(tx, &problem, body.Tags); err != nil {
+			return err
+		}
+		return linkProblem(tx, &link)
+	})
+	if err == errLabelTaken {
+		return c.JSON(http.StatusConflict, echo.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "could not create problem"})
+	}
+
+	scoreboard.Publish(db, contest.ID)
+
+	link.Problem = problem
+	return c.JSON(http.StatusCreated, link.View())
+}
+
+// setProblemTags replaces a problem's tags, creating the tags that do not exist yet
+func setProblemTags(tx *gorm.DB, problem *models.Problem, names []string) error {
+	names = models.NormalizeTags(names)
+
+	tags := []models.Tag{}
+	if len(names) > 0 {
+		for _, name := range names {
+			tag := models.Tag{ID: uuid.New(), Name: name}
+			if err := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "name"}}, DoNothing: true}).Create(&tag).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("name IN ?", names).Order("name ASC").Find(&tags).Error; err != nil {
+			return err
+		}
+	}
+
+	problem.Tags = tags
+	return tx.Model(problem).Association("Tags").Replace(tags)
+}
+
+// errLabelTaken is returned when another problem of the contest already has the label
+var errLabelTaken = errors.New("label is already used in this contest")
+
+// linkProblem adds a problem to the end of a contest. Without a label it gets
+// the first default label the contest is not using yet.
+func linkProblem(tx *gorm.DB, link *models.ContestProblem) error {
+	var last struct{ OrderIndex *int }
+	if err := tx.Model(&models.ContestProblem{}).Select("MAX(order_index) AS order_index").Where("contest_id = ?", link.ContestID).Scan(&last).Error; err != nil {
+		return err
+	}
+	if last.OrderIndex != nil {
+		link.OrderIndex = *last.OrderIndex + 1
+	}
+
+	if link.Label == "" {
+		var count int64
+		if err := tx.Model(&models.ContestProblem{}).Where("contest_id = ?", link.ContestID).Count(&count).Error; err != nil {
+			return err
+		}
+		for index := int(count); ; index++ {
+			link.Label = models.ProblemLabel(index)
+			taken, err := labelTaken(tx, *link)
+			if err != nil {
+				return err
+			}
+			if !taken {
+				break
+			}
+		}
+	} else if taken, err := labelTaken(tx, *link); err != nil {
+		return err
+	} else if taken {
+		return errLabelTaken
+	}
+
+	return tx.Create(link).Error
+}
+
+// labelTaken reports whether another problem of the contest uses the link's label
+func labelTaken(tx *gorm.DB, link models.ContestProblem) (bool, error) {
+	var count int64
+	err := tx.Model(&models.ContestProblem{}).
+		Where("contest_id = ? AND label = ? AND problem_id <> ?", link.ContestID, link.Label, link.ProblemID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Add an existing problem to a contest
+func AddContestProblem(c echo.Context) error {
+	var body struct {
+		ProblemID string `json:"problem_id"`
+		Label     string `json:"label"`
+		Points    *int   `json:"points"`
+		Color     string `json:"color"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	}
+
+	db := config.DB
+
+	contest, ok, err := loadContest(c, c.Param("id"))
+	if !ok {
+		return err
+	}
+
+	var problem models.Problem
+	if err := db.First(&problem, "id = ?", body.ProblemID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "problem not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+	}
+
+	link := models.ContestProblem{ContestID: contest.ID, ProblemID: problem.ID, Label: strings.TrimSpace(body.Label), Points: models.DefaultProblemPoints, Color: body.Color}
+	if body.Points != nil {
+		if *body.Points <= 0 {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "points must be positive"})
+		}
+		link.Points = *body.Points
+	}
+	if !models.IsValidColor(link.Color) {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "color must be #rrggbb"})
+	}
+
+	var existing int64
+	if err := db.Model(&models.ContestProblem{}).Where("contest_id = ? AND problem_id = ?", contest.ID, problem.ID).Count(&existing).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+	}
+	if existing > 0 {
+		return c.JSON(http.StatusConflict, echo.Map{"error": "problem is already in this contest"})
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return linkProblem(tx, &link)
+	})
+	if err == errLabelTaken {
+		return c.JSON(http.StatusConflict, echo.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "could not add problem"})
+	}
+
+	scoreboard.Publish(db, contest.ID)
+
+	link.Problem = problem
+	return c.JSON(http.StatusCreated, link.View())
+}
+
+// Change the label, points or colour of a problem in a contest
+func UpdateContestProblem(c echo.Context) error {
+	var body struct {
+		Label  *string `json:"label"`
+		Points *int    `json:"points"`
+		Color  *string `json:"color"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	}
+
+	db := config.DB
+
+	var link models.ContestProblem
+	if err := db.Preload("Problem").First(&link, "contest_id = ? AND problem_id = ?", c.Param("id"), c.Param("problem_id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "problem is not in this contest"})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+	}
+
+	if body.Label != nil {
+		link.Label = strings.TrimSpace(*body.Label)
+		if link.Label == "" {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "label cannot be empty"})
+		}
+		taken, err := labelTaken(db, link)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+		}
+		if taken {
+			return c.JSON(http.StatusConflict, echo.Map{"error": errLabelTaken.Error()})
+		}
+	}
+	if body.Points != nil {
+		if *body.Points <= 0 {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "points must be positive"})
+		}
+		link.Points = *body.Points
+	}
+	if body.Color != nil {
+		if !models.IsValidColor(*body.Color) {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "color must be #rrggbb"})
+		}
+		link.Color = *body.Color
+	}
+
+	if err := db.Model(&link).Updates(map[string]interface{}{"label": link.Label, "points": link.Points, "color": link.Color}).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "could not update contest problem"})
+	}
+
+	// Scores stored on dynamic submissions depend on the problem's points
+	if body.Points != nil {
+		var contest models.Contest
+		if err := db.First(&contest, "id = ?", link.ContestID).Error; err != nil {
+			return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+		}
+		if contest.ScoringMode == models.ScoringDynamic {
+			if err := scoreboard.Rebuild(db, contest); err != nil {
+				return c.JSON(http.StatusInternalServerError, echo.Map{"error": "contest problem updated but standings could not be rebuilt"})
+			}
+		}
+	}
+
+	scoreboard.Publish(db, link.ContestID)
+
+	return c.JSON(http.StatusOK, link.View())
+}
+
+// Reorder the problems of a contest. The body lists every problem of the contest in its new order.
+func ReorderContestProblems(c echo.Context) error {
+	var body struct {
+		ProblemIDs []uuid.UUID `json:"problem_ids"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	}
+
+	db := config.DB
+
+	contest, ok, err := loadContest(c, c.Param("id"))
+	if !ok {
+		return err
+	}
+
+	var current []uuid.UUID
+	if err := db.Model(&models.ContestProblem{}).Where("contest_id = ?", contest.ID).Pluck("problem_id", &current).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+	}
+
+	linked := make(map[uuid.UUID]bool, len(current))
+	for _, problemID := range current {
+		linked[problemID] = true
+	}
+	for _, problemID := range body.ProblemIDs {
+		if !linked[problemID] {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "problem_ids must list every problem of the contest exactly once"})
+		}
+		delete(linked, problemID)
+	}
+	if len(linked) > 0 {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "problem_ids must list every problem of the contest exactly once"})
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for index, problemID := range body.ProblemIDs {
+			if err := tx.Model(&models.ContestProblem{}).
+				Where("contest_id = ? AND problem_id = ?", contest.ID, problemID).
+				Update("order_index", index).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "could not reorder problems"})
+	}
+
+	scoreboard.Publish(db, contest.ID)
+
+	return getProblemsByContestID(c, false)
+}
+
+// Remove a problem from a contest, keeping it in the archive
+func RemoveContestProblem(c echo.Context) error {
+	db := config.DB
+
+	contest, ok, err := loadContest(c, c.Param("id"))
+	if !ok {
+		return err
+	}
+
+	result := db.Where("contest_id = ? AND problem_id = ?", contest.ID, c.Param("problem_id")).Delete(&models.ContestProblem{})
+	if result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "could not remove problem"})
+	}
+	if result.RowsAffected == 0 {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "problem is not in this contest"})
+	}
+
+	scoreboard.Publish(db, contest.ID)
+
+	return c.JSON(http.StatusOK, echo.Map{"message": "problem removed from contest"})
+}
+
+// Update a problem
+func UpdateProblem(c echo.Context) error {
+	problemID := c.Param("id")
+	db := config.DB
+	var body struct {
+		Title       string    `json:"title"`
+		Description string    `json:"description"`
+		IsPublic    *bool     `json:"is_public"`
+		Difficulty  *int      `json:"difficulty"`
+		Source      *string   `json:"source"`
+		Tags        *[]string `json:"tags"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	}
+	var problem models.Problem
+	if err := db.First(&problem, "id = ?", problemID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "problem not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+	}
+	problem.Title = body.Title
+	problem.Description = body.Description
+	if body.IsPublic != nil {
+		problem.IsPublic = *body.IsPublic
+	}
+	if body.Difficulty != nil {
+		if *body.Difficulty < 0 {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "difficulty cannot be negative"})
+		}
+		problem.Difficulty = *body.Difficulty
+	}
+	if body.Source != nil {
+		problem.Source = *body.Source
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Tags").Save(&problem).Error; err != nil {
+			return err
+		}
+		if body.Tags == nil {
+			return tx.Model(&problem).Association("Tags").Find(&problem.Tags)
+		}
+		return setProblemTags(tx, &problem, *body.Tags)
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "could not update problem"})
+	}
+	return c.JSON(http.StatusOK, problem)
+}
+
+// Delete a problem and remove it from its contests
+func DeleteProblem(c echo.Context) error {
+	problemID := c.Param("id")
+	db := config.DB
+
+	// Check if the problem exists
+	var problem models.Problem
+	if err := db.First(&problem, "id = ?", problemID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "problem not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+	}
+
+	var contestIDs []uuid.UUID
+	if err := db.Model(&models.ContestProblem{}).Where("problem_id = ?", problem.ID).Pluck("contest_id", &contestIDs).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+	}
+
+	// Delete the problem along with its contest links and tags
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("problem_id = ?", problem.ID).Delete(&models.ContestProblem{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&problem).Association("Tags").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(&problem).Error
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "could not delete problem"})
+	}
+
+	for _, contestID := range contestIDs {
+		scoreboard.Publish(db, contestID)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"message": "problem deleted successfully"})
+}
+
+// Get the sample test cases for a problem
+func GetSampleTestCasesByProblemID(c echo.Context) error {
+	user, ok, err := currentUserOrError(c)
+	if !ok {
+		return err
+	}
+
+	var problem models.Problem
+	if err := config.DB.First(&problem, "id = ?", c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "problem not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+	}
+
+	visible, _, err := problemAccess(config.DB, problem, user.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+	}
+	if !visible {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": "problem is not available yet"})
+	}
+
+	return getTestCasesByProblemID(c, true)
+}
+
+// Get all test cases for a problem
+func GetAllTestCasesByProblemID(c echo.Context) error {
+	return getTestCasesByProblemID(c, false)
+}
+
+func getTestCasesByProblemID(c echo.Context, samplesOnly bool) error {
+	problemID := c.Param("id")
+	db := config.DB
+	var testCases []models.TestCase
+
+	if err := db.Scopes(testCaseScope(samplesOnly)).Where("problem_id = ?", problemID).Find(&testCases).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "failed to retrieve test cases"})
+	}
+
+	if len(testCases) == 0 {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "no test cases found for this problem"})
+	}
+
+	return c.JSON(http.StatusOK, testCases)
+}
+
+// Create Testcase for a problem
+func CreateTestCase(c echo.Context) error {
+	problemID := c.Param("id")
+	var body struct {
+		Input    string `json:"input"`
+		Output   string `json:"output"`
+		IsSample bool   `json:"is_sample"`
+	}
+
+	if err := c.Bind(&body); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	}
+
+	db := config.DB
+	var problem models.Problem
+
+	if err := db.First(&problem, "id = ?", problemID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "problem not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+	}
+
+	testCase := models.TestCase{
+		ID:        uuid.New(),
+		ProblemID: problem.ID,
+		Input:     body.Input,
+		Output:    body.Output,
+		IsSample:  body.IsSample,
+	}
+
+	if err := db.Create(&testCase).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "could not create test case"})
+	}
+
+	return c.JSON(http.StatusCreated, testCase)
+}
+
+// Update Testcase for a problem
+func UpdateTestCase(c echo.Context) error {
+	testCaseID := c.Param("id")
+	db := config.DB
+	var body struct {
+		Input    string `json:"input"`
+		Output   string `json:"output"`
+		IsSample *bool  `json:"is_sample"` // Left unchanged when omitted
+	}
+
+	if err := c.Bind(&body); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	}
+
+	var testCase models.TestCase
+	if err := db.First(&testCase, "id = ?", testCaseID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "test case not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+	}
+
+	testCase.Input = body.Input
+	testCase.Output = body.Output
+	if body.IsSample != nil {
+		testCase.IsSample = *body.IsSample
+	}
+
+	if err := db.Save(&testCase).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "could not update test case"})
+	}
+
+	return c.JSON(http.StatusOK, testCase)
+}
+
+// Delete Testcase for a problem
+func DeleteTestCase(c echo.Context) error {
+	testCaseID := c.Param("id")
+	db := config.DB
+
+	// Check if the test case exists
+	var testCase models.TestCase
+	if err := db.First(&testCase, "id = ?", testCaseID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "test case not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+	}
+
+	// Delete the test case
+	if err := db.Delete(&testCase).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "could not delete test case"})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"message": "test case deleted successfully"})
+}
+
+// Handle submission for a problem by the authenticated user
+func HandleSubmission(c echo.Context) error {
+	problemID := c.Param("problem_id")
+	db := config.DB
+	if problemID == "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "problem_id is required"})
+	}
+	var body struct {
+		SourceCode string `json:"source_code"`
+		Language   string `json:"language"`
+		ContestID  string `json:"contest_id"` // Empty to practise the problem from the archive
+	}
+	if err := c.Bind(&body); err != nil {
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
 	}
 
-	db := config.DB
-	var problem models.Problem
+	// The submitting user always comes from the token
+	user, ok, err := currentUserOrError(c)
+	if !ok {
+		return err
+	}
+	// Validate problem exists
+	var problem models.Problem
+	if err := db.First(&problem, "id = ?", problemID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "problem not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+	}
+
+	// Submissions to a contest are rejected before it starts, ranked while it
+	// runs for registered participants, and kept as practice once it has ended.
+	// Submissions outside a contest are always practice.
+	var contestID *uuid.UUID
+	isPractice := true
+	if body.ContestID != "" {
+		contest, ok, err := loadStartedContest(c, body.ContestID, user.ID)
+		if !ok {
+			return err
+		}
+
+		var linked int64
+		if err := db.Model(&models.ContestProblem{}).Where("contest_id = ? AND problem_id = ?", contest.ID, problem.ID).Count(&linked).Error; err != nil {
+			return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+		}
+		if linked == 0 {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "problem is not in this contest"})
+		}
+
+		contestID = &contest.ID
+		isPractice = contest.Phase(time.Now()) == models.ContestEnded
+	}
+
+	if isPractice {
+		// A problem still used by a running or upcoming contest cannot be practised
+		_, practice, err := problemAccess(db, problem, user.ID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+		}
+		if !practice {
+			return c.JSON(http.StatusForbidden, echo.Map{"error": "problem is not open for practice"})
+		}
+	} else {
+		registered, err := isRegistered(db, *contestID, user.ID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+		}
+		if !registered {
+			return c.JSON(http.StatusForbidden, echo.Map{"error": "register for the contest before submitting"})
+		}
+	}
+
+	var testCases []models.TestCase
+
+	if err := db.Where("problem_id = ?", problem.ID).Find(&testCases).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "failed to retrieve test cases"})
+	}
+	if len(testCases) == 0 {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "no test cases found for this problem"})
+	}
+
+	var language models.Language
+	if err := db.First(&language, "name = ?", body.Language).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "language not supported"})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+	}
+
+	// Generate callback URL for this submission
+	baseURL := config.GetEnv("BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:1323" // Default fallback
+	}
+	callbackURL := fmt.Sprintf("%s/callback/submission", baseURL)
+
+	// Concatenate all test case inputs and outputs separated by newline
+	var allInputs []string
+	var allOutputs []string
+	hasHiddenTests := false
+	for _, tc := range testCases {
+		allInputs = append(allInputs, tc.Input)
+		allOutputs = append(allOutputs, tc.Output)
+		if !tc.IsSample {
+			hasHiddenTests = true
+		}
+	}
+	combinedInput := strings.Join(allInputs, "\n")
+	combinedOutput := strings.Join(allOutputs, "\n")
+
+	submission := models.Submission{
+		ID:             uuid.New(),
+		ProblemID:      problem.ID,
+		UserID:         user.ID,
+		ContestID:      contestID,
+		SubmittedAt:    time.Now(),
+		Status:         models.StatusQueued,
+		Result:         "pending", // Verdict is set once judged
+		SourceCode:     body.SourceCode,
+		Language:       body.Language,
+		Score:          0,                   // Initial score
+		StdInput:       combinedInput,       // All test case inputs separated by newline
+		ExpectedOutput: combinedOutput,      // All test case outputs separated by newline
+		StdOutput:      "",                  // Will be filled after execution
+		StdError:       "",                  // Will be filled after execution
+		CompileOutput:  "",                  // Will be filled after compilation
+		ExitSignal:     0,                   // Will be filled after execution
+		ExitCode:       0,                   // Will be filled after execution
+		CallbackURL:    callbackURL,         // Set callback URL for worker to call back
+		HasHiddenTests: hasHiddenTests,
+		IsPractice:     isPractice,
+
+		PublishAttempts: 1, // Written to the outbox with the submission
+	}
+	submission.QueuedAt = &submission.SubmittedAt
+
+	// Store the submission and its queue message together so neither can exist without the other
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&submission).Error; err != nil {
+			return err
+		}
+		if !submission.IsPractice {
+			if err := scoreboard.Refresh(tx, *submission.ContestID, submission.UserID, submission.ProblemID); err != nil {
+				return err
+			}
+		}
+		return outbox.Enqueue(tx, rabbitmq.NewSubmissionPayload(submission, language))
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "could not create submission"})
+	}
+
+	// Let the outbox relay send the submission to RabbitMQ for processing
+	outbox.Notify()
+
+	// Ranked submissions show up on the leaderboard as pending
+	if !submission.IsPractice {
+		scoreboard.Publish(db, *submission.ContestID)
+	}
+
+	return c.JSON(http.StatusCreated, submission.ForContestant())
+}
+
+// loadOwnSubmission loads a submission and checks that it belongs to the authenticated user
+func loadOwnSubmission(c echo.Context, submissionID string) (models.Submission, bool, error) {
+	var submission models.Submission
+
+	user, ok, err := currentUserOrError(c)
+	if !ok {
+		return submission, false, err
+	}
+
+	if err := config.DB.First(&submission, "id = ?", submissionID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return submission, false, c.JSON(http.StatusNotFound, echo.Map{"error": "submission not found"})
+		}
+		return submission, false, c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+	}
+
+	if submission.UserID != user.ID {
+		return submission, false, c.JSON(http.StatusForbidden, echo.Map{"error": "submission belongs to another user"})
+	}
+
+	return submission, true, nil
+}
+
+// Get one of the authenticated user's submissions
+func GetSubmissionByID(c echo.Context) error {
+	submission, ok, err := loadOwnSubmission(c, c.Param("id"))
+	if !ok {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, submission.ForContestant())
+}
+
+// Get any submission with its source code and outputs
+func AdminGetSubmissionByID(c echo.Context) error {
+	var submission models.Submission
+
+	if err := config.DB.Preload("Problem").Preload("User").First(&submission, "id = ?", c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "submission not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+	}
+
+	return c.JSON(http.StatusOK, submission)
+}
+
+// List the authenticated user's submissions
+func GetMySubmissions(c echo.Context) error {
+	user, ok, err := currentUserOrError(c)
+	if !ok {
+		return err
+	}
+
+	return listSubmissions(c, &user.ID)
+}
+
+// List all submissions
+func AdminGetSubmissions(c echo.Context) error {
+	return listSubmissions(c, nil)
+}
+
+// listSubmissions lists submissions newest first, filtered by the contest_id,
+// problem_id, user_id, language, result, status, from and to query parameters.
+// When userID is set only that user's submissions are listed.
+func listSubmissions(c echo.Context, userID *uuid.UUID) error {
+	page, perPage, err := parsePagination(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	query := config.DB.
+		Table("submissions").
+		Joins("JOIN problems ON submissions.problem_id = problems.id").
+		Joins("JOIN users ON submissions.user_id = users.id")
+	query, err = filterSubmissions(c, query, userID)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	// Start a new session so counting does not leak into the page query
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "failed to retrieve submissions"})
+	}
+
+	submissions := []models.SubmissionSummary{}
+	if err := query.
+		Select("submissions.id, submissions.problem_id, problems.title AS problem_title, submissions.user_id, users.username, submissions.contest_id, submissions.submitted_at, submissions.status, submissions.result, submissions.language, submissions.score, submissions.cpu_time_ms, submissions.memory_kb, submissions.is_practice").
+		Order("submissions.submitted_at DESC").
+		Offset((page - 1) * perPage).
+		Limit(perPage).
+		Scan(&submissions).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "failed to retrieve submissions"})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"submissions": submissions,
+		"page":        page,
+		"per_page":    perPage,
+		"total":       total,
+	})
+}
+
+// filterSubmissions applies the filters of a submission list to query. When
+// userID is set only that user's submissions are kept and the user_id filter
+// is ignored.
+func filterSubmissions(c echo.Context, query *gorm.DB, userID *uuid.UUID) (*gorm.DB, error) {
+	if userID != nil {
+		query = query.Where("submissions.user_id = ?", *userID)
+	} else if value := c.QueryParam("user_id"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid user_id")
+		}
+		query = query.Where("submissions.user_id = ?", id)
+	}
+
+	for param, column := range map[string]string{"contest_id": "submissions.contest_id", "problem_id": "submissions.problem_id"} {
+		value := c.QueryParam(param)
+		if value == "" {
+			continue
+		}
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s", param)
+		}
+		query = query.Where(column+" = ?", id)
+	}
+
+	if language := c.QueryParam("language"); language != "" {
+		query = query.Where("submissions.language = ?", language)
+	}
+	if result := c.QueryParam("result"); result != "" {
+		query = query.Where("submissions.result = ?", result)
+	}
+	if status := c.QueryParam("status"); status != "" {
+		if !models.IsValidStatus(status) {
+			return nil, fmt.Errorf("invalid status")
+		}
+		query = query.Where("submissions.status = ?", status)
+	}
+
+	from, err := parseOptionalTime(c.QueryParam("from"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid from format")
+	}
+	if from != nil {
+		query = query.Where("submissions.submitted_at >= ?", *from)
+	}
+	to, err := parseOptionalTime(c.QueryParam("to"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid to format")
+	}
+	if to != nil {
+		query = query.Where("submissions.submitted_at < ?", *to)
+	}
+
+	return query, nil
+}
+
+// Pagination defaults for list endpoints
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
 
-	if err := db.First(&problem, "id = ?", problemID).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return c.JSON(http.StatusNotFound, echo.Map{"error": "problem not found"})
+// parsePagination reads the page and per_page query parameters
+func parsePagination(c echo.Context) (page, perPage int, err error) {
+	page, perPage = 1, defaultPerPage
+
+	if value := c.QueryParam("page"); value != "" {
+		if page, err = strconv.Atoi(value); err != nil || page < 1 {
+			return 0, 0, fmt.Errorf("invalid page")
+		}
+	}
+	if value := c.QueryParam("per_page"); value != "" {
+		if perPage, err = strconv.Atoi(value); err != nil || perPage < 1 || perPage > maxPerPage {
+			return 0, 0, fmt.Errorf("per_page must be between 1 and %d", maxPerPage)
 		}
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
 	}
 
-	testCase := models.TestCase{
-		ID:        uuid.New(),
-		ProblemID: problem.ID,
-		Input:     body.Input,
-		Output:    body.Output,
+	return page, perPage, nil
+}
+
+// Stream real-time updates for one of the authenticated user's submissions
+func SubscribeSubmissionEvents(c echo.Context) error {
+	submission, ok, err := loadOwnSubmission(c, c.Param("id"))
+	if !ok {
+		return err
 	}
 
-	if err := db.Create(&testCase).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "could not create test case"})
+	return sse.HandleSSEConnection(c, submission.UserID.String(), submission.ID.String())
+}
+
+// Get the ranked standings of a contest using its scoring mode, frozen during the freeze period
+func GetLeaderboardByContestID(c echo.Context) error {
+	return getLeaderboardByContestID(c, false)
+}
+
+// Get the live standings of a contest, ignoring the freeze
+func AdminGetLeaderboardByContestID(c echo.Context) error {
+	return getLeaderboardByContestID(c, true)
+}
+
+func getLeaderboardByContestID(c echo.Context, live bool) error {
+	contest, ok, err := loadScoreboardContest(c, live)
+	if !ok {
+		return err
 	}
 
-	return c.JSON(http.StatusCreated, testCase)
+	board, err := scoreboard.Load(config.DB, contest, live)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "failed to retrieve leaderboard"})
+	}
+
+	return c.JSON(http.StatusOK, board)
 }
 
-// Update Testcase for a problem
-func UpdateTestCase(c echo.Context) error {
-	testCaseID := c.Param("id")
-	db := config.DB
-	var body struct {
-		Input  string `json:"input"`
-		Output string `json:"output"`
+// Stream the standings of a contest: a snapshot first, then deltas as results come in
+func SubscribeLeaderboardEvents(c echo.Context) error {
+	return subscribeLeaderboardEvents(c, false)
+}
+
+// Stream the live standings of a contest, ignoring the freeze
+func AdminSubscribeLeaderboardEvents(c echo.Context) error {
+	return subscribeLeaderboardEvents(c, true)
+}
+
+func subscribeLeaderboardEvents(c echo.Context, live bool) error {
+	contest, ok, err := loadScoreboardContest(c, live)
+	if !ok {
+		return err
 	}
 
-	if err := c.Bind(&body); err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	return sse.HandleLeaderboardConnection(c, contest.ID.String(), live, scoreboard.Loader(config.DB, contest.ID))
+}
+
+// loadScoreboardContest loads a contest whose scoreboard may be shown. The
+// scoreboard lists the contest's problems, so contestants see it under the
+// same rules as the problems themselves, while admins always see the live one.
+func loadScoreboardContest(c echo.Context, live bool) (models.Contest, bool, error) {
+	if live {
+		return loadContest(c, c.Param("contest_id"))
 	}
 
-	var testCase models.TestCase
-	if err := db.First(&testCase, "id = ?", testCaseID).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return c.JSON(http.StatusNotFound, echo.Map{"error": "test case not found"})
-		}
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+	user, ok, err := currentUserOrError(c)
+	if !ok {
+		return models.Contest{}, false, err
 	}
 
-	testCase.Input = body.Input
-	testCase.Output = body.Output
+	return loadStartedContest(c, c.Param("contest_id"), user.ID)
+}
 
-	if err := db.Save(&testCase).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "could not update test case"})
+// Get the scoreboard of a finished contest as it stood at the time given by the "at" query parameter
+func GetLeaderboardAt(c echo.Context) error {
+	return getLeaderboardAt(c, false)
+}
+
+// Get the scoreboard of a contest as it stood at any time, even during the contest or the freeze
+func AdminGetLeaderboardAt(c echo.Context) error {
+	return getLeaderboardAt(c, true)
+}
+
+func getLeaderboardAt(c echo.Context, admin bool) error {
+	contest, ok, err := loadHistoryContest(c, admin)
+	if !ok {
+		return err
 	}
 
-	return c.JSON(http.StatusOK, testCase)
+	at, err := time.Parse(time.RFC3339, c.QueryParam("at"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid at format"})
+	}
+	if at.Before(contest.StartTime) || at.After(contest.EndTime) {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "at must be within the contest"})
+	}
+
+	board, err := scoreboard.LoadAt(config.DB, contest, at)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "failed to retrieve leaderboard"})
+	}
+
+	return c.JSON(http.StatusOK, board)
 }
 
-// Delete Testcase for a problem
-func DeleteTestCase(c echo.Context) error {
-	testCaseID := c.Param("id")
-	db := config.DB
+// Get the rank changes of a finished contest over time
+func GetStandingsHistory(c echo.Context) error {
+	return getStandingsHistory(c, false)
+}
 
-	// Check if the test case exists
-	var testCase models.TestCase
-	if err := db.First(&testCase, "id = ?", testCaseID).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return c.JSON(http.StatusNotFound, echo.Map{"error": "test case not found"})
-		}
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+// Get the rank changes of a contest over time, even during the contest or the freeze
+func AdminGetStandingsHistory(c echo.Context) error {
+	return getStandingsHistory(c, true)
+}
+
+func getStandingsHistory(c echo.Context, admin bool) error {
+	contest, ok, err := loadHistoryContest(c, admin)
+	if !ok {
+		return err
 	}
 
-	// Delete the test case
-	if err := db.Delete(&testCase).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "could not delete test case"})
+	history, err := scoreboard.LoadHistory(config.DB, contest)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "failed to retrieve standings history"})
 	}
 
-	return c.JSON(http.StatusOK, echo.Map{"message": "test case deleted successfully"})
+	return c.JSON(http.StatusOK, history)
 }
 
-// Get all submissions for a problem
-func GetAllSubmissionsByProblemID(c echo.Context) error {
-	problemID := c.Param("id")
-	db := config.DB
-	var submissions []models.Submission
+// loadHistoryContest loads a contest whose standings history may be shown.
+// Contestants only see it once the contest has ended and its freeze is lifted,
+// since it reveals every verdict.
+func loadHistoryContest(c echo.Context, admin bool) (models.Contest, bool, error) {
+	contest, ok, err := loadContest(c, c.Param("contest_id"))
+	if !ok || admin {
+		return contest, ok, err
+	}
 
-	if err := db.Where("problem_id = ?", problemID).Find(&submissions).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "failed to retrieve submissions"})
+	now := time.Now()
+	if contest.Phase(now) != models.ContestEnded || contest.IsFrozen(now) {
+		return contest, false, c.JSON(http.StatusForbidden, echo.Map{"error": "standings history is available once the contest has ended and the scoreboard is unfrozen"})
+	}
+
+	return contest, true, nil
+}
+
+// Rebuild the standings of a contest from its submissions
+func RebuildStandings(c echo.Context) error {
+	contest, ok, err := loadContest(c, c.Param("id"))
+	if !ok {
+		return err
 	}
 
-	if len(submissions) == 0 {
-		return c.JSON(http.StatusNotFound, echo.Map{"error": "no submissions found for this problem"})
+	if err := scoreboard.Rebuild(config.DB, contest); err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "could not rebuild standings"})
 	}
+	scoreboard.Publish(config.DB, contest.ID)
 
-	return c.JSON(http.StatusOK, submissions)
+	return c.JSON(http.StatusOK, echo.Map{"message": "standings rebuilt successfully"})
 }
 
-// Handle submission for a problem
-func HandleSubmission(c echo.Context) error {
-	userID := c.Param("user_id")
-	problemID := c.Param("problem_id")
+// Lift the scoreboard freeze of a contest that has ended
+func UnfreezeContest(c echo.Context) error {
 	db := config.DB
-	if userID == "" || problemID == "" {
-		return c.JSON(http.StatusBadRequest, echo.Map{"error": "user_id and problem_id are required"})
+
+	contest, ok, err := loadContest(c, c.Param("id"))
+	if !ok {
+		return err
 	}
-	var body struct {
-		SourceCode string `json:"source_code"`
-		Language   string `json:"language"`
+
+	if contest.Phase(time.Now()) != models.ContestEnded {
+		return c.JSON(http.StatusConflict, echo.Map{"error": "contest has not ended yet"})
 	}
-	if err := c.Bind(&body); err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	if contest.UnfrozenAt != nil {
+		return c.JSON(http.StatusOK, contest)
 	}
 
-	// Validate user exists
-	var user models.User
-	if err := db.First(&user, "id = ?", userID).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return c.JSON(http.StatusNotFound, echo.Map{"error": "user not found"})
-		}
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+	now := time.Now()
+	contest.UnfrozenAt = &now
+	if err := db.Model(&contest).Update("unfrozen_at", now).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "could not unfreeze contest"})
 	}
-	// Validate problem exists
-	var problem models.Problem
-	if err := db.First(&problem, "id = ?", problemID).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return c.JSON(http.StatusNotFound, echo.Map{"error": "problem not found"})
-		}
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+
+	scoreboard.Publish(db, contest.ID)
+
+	return c.JSON(http.StatusOK, contest)
+}
+
+// Export the reveal sequence for a resolver ceremony of a contest that has ended
+func GetResolverData(c echo.Context) error {
+	contest, ok, err := loadContest(c, c.Param("id"))
+	if !ok {
+		return err
 	}
 
-	var testCases []models.TestCase
+	if contest.Phase(time.Now()) != models.ContestEnded {
+		return c.JSON(http.StatusConflict, echo.Map{"error": "contest has not ended yet"})
+	}
 
-	if err := db.Where("problem_id = ?", problem.ID).Find(&testCases).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "failed to retrieve test cases"})
+	data, err := scoreboard.LoadResolver(config.DB, contest)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "failed to build resolver data"})
 	}
-	if len(testCases) == 0 {
-		return c.JSON(http.StatusNotFound, echo.Map{"error": "no test cases found for this problem"})
+
+	return c.JSON(http.StatusOK, data)
+}
+
+// Get the fastest accepted submission of each user for a problem, either among
+// the ranked submissions of one of its contests (contest_id) or among practice
+// submissions. Rankings stay hidden while any contest using the problem has
+// not ended, since they would show who solved it and how.
+func GetFastestSubmissionsByProblemID(c echo.Context) error {
+	db := config.DB
+
+	user, ok, err := currentUserOrError(c)
+	if !ok {
+		return err
 	}
 
-	var language models.Language
-	if err := db.First(&language, "name = ?", body.Language).Error; err != nil {
+	var problem models.Problem
+	if err := db.First(&problem, "id = ?", c.Param("id")).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return c.JSON(http.StatusNotFound, echo.Map{"error": "language not supported"})
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "problem not found"})
 		}
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
 	}
 
-	// Generate callback URL for this submission
-	baseURL := config.GetEnv("BASE_URL")
-	if baseURL == "" {
-		baseURL = "http://localhost:1323" // Default fallback
-	}
-	callbackURL := fmt.Sprintf("%s/callback/submission", baseURL)
-
-	// Concatenate all test case inputs and outputs separated by newline
-	var allInputs []string
-	var allOutputs []string
-	for _, tc := range testCases {
-		allInputs = append(allInputs, tc.Input)
-		allOutputs = append(allOutputs, tc.Output)
+	visible, practice, err := problemAccess(db, problem, user.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
 	}
-	combinedInput := strings.Join(allInputs, "\n")
-	combinedOutput := strings.Join(allOutputs, "\n")
-
-	submission := models.Submission{
-		ID:             uuid.New(),
-		ProblemID:      problem.ID,
-		UserID:         user.ID,
-		ContestID:      problem.ContestID,
-		SubmittedAt:    time.Now(),
-		Result:         "pending", // Initial status
-		SourceCode:     body.SourceCode,
-		Language:       body.Language,
-		Score:          0,                   // Initial score
-		StdInput:       combinedInput,       // All test case inputs separated by newline
-		ExpectedOutput: combinedOutput,      // All test case outputs separated by newline
-		StdOutput:      "",                  // Will be filled after execution
-		StdError:       "",                  // Will be filled after execution
-		CompileOutput:  "",                  // Will be filled after compilation
-		ExitSignal:     0,                   // Will be filled after execution
-		ExitCode:       0,                   // Will be filled after execution
-		CallbackURL:    callbackURL,         // Set callback URL for worker to call back
+	if !visible {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": "problem is not available yet"})
 	}
-	if err := db.Create(&submission).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "could not create submission"})
+	if !practice {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": "fastest submissions are hidden until the problem's contests end"})
 	}
 
-	// Prepare RabbitMQ payload
-	rabbitmqPayload := models.RabbitMQPayload{
-		SubmissionID:   submission.ID,
-		ProblemID:      submission.ProblemID,
-		UserID:         submission.UserID,
-		Language:       submission.Language,
-		SourceCode:     submission.SourceCode,
-		SourceFileName: language.SrcFile,
-		Status:         submission.Result,
-		Score:          submission.Score,
-		TimeLimit:      language.TimeLimit,
-		WallTimeLimit:  language.WallLimit,
-		MemoryLimit:    language.MemoryLimit,
-		StackLimit:     language.StackLimit,
-		OutputLimit:    language.OutputLimit,
-		StdIn:         submission.StdInput,
-		StdOut:        submission.ExpectedOutput,
-		CompileCmd:    language.CompileCommand,
-		RunCmd:        language.RunCommand,
-		CallBackURL:   callbackURL,
-	}
-
-	// Send submission to RabbitMQ for processing
-	if err := rabbitmq.SendSubmissionToQueue(rabbitmqPayload); err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "failed to send submission to queue"})
-	}
-	return c.JSON(http.StatusCreated, submission)
-}
-
-func GetSubmissionsByContestID(c echo.Context) error {
-	contestID := c.Param("contest_id")
-	db := config.DB
-	var submissions []models.Submission
-	if err := db.Preload("Problem").Preload("User").Where("contest_id = ?", contestID).Find(&submissions).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "failed to retrieve submissions"})
-	}
-	if len(submissions) == 0 {
-		return c.JSON(http.StatusNotFound, echo.Map{"error": "no submissions found for this contest"})
-	}
-	return c.JSON(http.StatusOK, submissions)
-}
+	// Keep each user's best run, then rank those by CPU time, memory and submission time
+	best := db.
+		Table("submissions").
+		Select("DISTINCT ON (submissions.user_id) submissions.id AS submission_id, submissions.user_id, users.username, submissions.language, submissions.cpu_time_ms, submissions.wall_time_ms, submissions.memory_kb, submissions.submitted_at").
+		Joins("JOIN users ON submissions.user_id = users.id").
+		Where("submissions.problem_id = ? AND submissions.result = ?", problem.ID, "AC").
+		Order("submissions.user_id, submissions.cpu_time_ms ASC, submissions.memory_kb ASC, submissions.submitted_at ASC")
 
-func GetLeaderboardByContestID(c echo.Context) error {
-	contestID := c.Param("contest_id")
-	db := config.DB
+	if contestID := c.QueryParam("contest_id"); contestID != "" {
+		id, err := uuid.Parse(contestID)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid contest_id"})
+		}
+
+		var linked int64
+		if err := db.Model(&models.ContestProblem{}).Where("contest_id = ? AND problem_id = ?", id, problem.ID).Count(&linked).Error; err != nil {
+			return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+		}
+		if linked == 0 {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "problem is not in this contest"})
+		}
 
-	var leaderboard []models.LeaderboardEntry
+		best = best.Where("submissions.contest_id = ? AND submissions.is_practice = ?", id, false)
+	} else {
+		best = best.Where("submissions.is_practice = ?", true)
+	}
 
+	var fastest []models.FastestSubmissionEntry
 	if err := db.
-		Table("submissions").
-		Select("user_id, users.username, SUM(score) as total_score, MIN(submitted_at) as first_submission").
-		Joins("JOIN users ON submissions.user_id = users.id").
-		Where("contest_id = ?", contestID).
-		Group("user_id, users.username").
-		Order("total_score DESC, first_submission ASC").
-		Scan(&leaderboard).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "failed to retrieve leaderboard"})
+		Table("(?) AS best", best).
+		Order("cpu_time_ms ASC, memory_kb ASC, submitted_at ASC").
+		Scan(&fastest).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "failed to retrieve fastest submissions"})
 	}
 
-	return c.JSON(http.StatusOK, leaderboard)
+	return c.JSON(http.StatusOK, fastest)
+}
+
+// parseMillisFromSeconds converts isolate's fractional seconds (e.g. "0.125") to whole milliseconds
+func parseMillisFromSeconds(s string) int {
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0
+	}
+	return int(math.Round(seconds * 1000))
 }
 
 // HMAC verification utilities
@@ -756,14 +2554,32 @@ func verifyHMAC(payload []byte, receivedSignature string, secret string) bool {
 	return hmac.Equal([]byte(receivedSignature), []byte(expectedSignature))
 }
 
-// extractSignatureFromHeader extracts the HMAC signature from the header
-func extractSignatureFromHeader(header string) (string, error) {
-	// Expected format: "sha256=<signature>"
-	fmt.Printf("Extracting signature from header: %s\n", header)
-	// if !strings.HasPrefix(header, "sha256=") {
-	// 	return "", fmt.Errorf("invalid signature format")
-	// }
-	return strings.TrimPrefix(header, "sha256="), nil
+// extractSignatureFromHeader extracts the key ID and HMAC signature from the header
+func extractSignatureFromHeader(header string) (keyID string, signature string, err error) {
+	// Expected format: "keyid=<key id>,sha256=<signature>", the key ID is optional
+	keyID = webhook.DefaultKeyID
+	for _, part := range strings.Split(header, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return "", "", fmt.Errorf("invalid signature format")
+		}
+		switch name {
+		case "keyid":
+			keyID = value
+		case "sha256":
+			signature = value
+		}
+	}
+	if keyID == "" || signature == "" {
+		return "", "", fmt.Errorf("invalid signature format")
+	}
+	return keyID, signature, nil
+}
+
+// callbackSignedContent rebuilds what the worker signed: "<timestamp>.<delivery id>.<body>"
+func callbackSignedContent(timestamp, deliveryID string, body []byte) []byte {
+	content := []byte(timestamp + "." + deliveryID + ".")
+	return append(content, body...)
 }
 
 // Callback endpoint for receiving submission results from workers
@@ -774,6 +2590,16 @@ func HandleSubmissionCallback(c echo.Context) error {
 		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "missing signature"})
 	}
 
+	// Reject callbacks without a unique delivery ID or outside the freshness window
+	timestamp := c.Request().Header.Get("X-OJ-Timestamp")
+	deliveryID := c.Request().Header.Get("X-OJ-Delivery")
+	if deliveryID == "" {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "missing delivery id"})
+	}
+	if err := replay.CheckTimestamp(timestamp); err != nil {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": err.Error()})
+	}
+
 	// Read the raw body for HMAC verification
 	body := make([]byte, 0)
 	if c.Request().Body != nil {
@@ -788,24 +2614,28 @@ c.Request().Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 	}
 
 	// Extract signature from header
-	sig, err := extractSignatureFromHeader(signature)
+	keyID, sig, err := extractSignatureFromHeader(signature)
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid signature format"})
 	}
 
-	// Verify HMAC
-	webhookSecret := config.GetEnv("WEBHOOK_SECRET")
-	if webhookSecret == "" {
+	// Verify HMAC with the active key the worker signed with
+	if !webhook.Configured() {
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "webhook secret not configured"})
 	}
+	webhookSecret, ok := webhook.Secret(keyID)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "unknown or revoked signing key"})
+	}
 
-	if !verifyHMAC(body, sig, webhookSecret) {
+	if !verifyHMAC(callbackSignedContent(timestamp, deliveryID, body), sig, webhookSecret) {
 		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "invalid signature"})
 	}
 
 	// Parse the callback payload
 	var callbackPayload struct {
 		SubmissionID  string `json:"submission_id"`
+		Status        string `json:"status"`
 		Result        string `json:"result"`
 		Score         int    `json:"score"`
 		StdOutput     string `json:"std_output"`
@@ -816,6 +2646,9 @@ c.Request().Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 		Time          string `json:"time"`
 		Memory        string `json:"memory"`
 		Message       string `json:"message"`
+		CPUTimeMs     int    `json:"cpu_time_ms"`
+		WallTimeMs    int    `json:"wall_time_ms"`
+		MemoryKB      int    `json:"memory_kb"`
 	}
 
 	if err := c.Bind(&callbackPayload); err != nil {
@@ -823,7 +2656,15 @@ c.Request().Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid payload"})
 	}
 
-	// Update submission in database
+	// Older workers only report final results
+	status := callbackPayload.Status
+	if status == "" {
+		status = models.StatusJudged
+	}
+	if !models.IsValidStatus(status) || status == models.StatusQueued || status == models.StatusCancelled {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid status"})
+	}
+
 	db := config.DB
 	var submission models.Submission
 
@@ -834,18 +2675,113 @@ c.Request().Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
 	}
 
-	// Update submission with results
-	submission.Result = callbackPayload.Result
-	submission.Score = callbackPayload.Score
-	submission.StdOutput = callbackPayload.StdOutput
-	submission.StdError = callbackPayload.StdError
-	submission.CompileOutput = callbackPayload.CompileOutput
-	submission.ExitSignal = callbackPayload.ExitSignal
-	submission.ExitCode = callbackPayload.ExitCode
+	// Results are only stored once the submission has finished
+	updates := map[string]interface{}{}
+	if models.IsTerminalStatus(status) {
+		if status == models.StatusFailed && callbackPayload.Result == "" {
+			callbackPayload.Result = "SE"
+		}
+
+		cpuTimeMs := callbackPayload.CPUTimeMs
+		memoryKB := callbackPayload.MemoryKB
+		// Older workers only report isolate's raw strings
+		if cpuTimeMs == 0 {
+			cpuTimeMs = parseMillisFromSeconds(callbackPayload.Time)
+		}
+		if memoryKB == 0 {
+			memoryKB, _ = strconv.Atoi(strings.TrimSpace(callbackPayload.Memory))
+		}
+
+		updates = map[string]interface{}{
+			"result":         callbackPayload.Result,
+			"score":          callbackPayload.Score,
+			"std_output":     callbackPayload.StdOutput,
+			"std_error":      callbackPayload.StdError,
+			"compile_output": callbackPayload.CompileOutput,
+			"exit_signal":    callbackPayload.ExitSignal,
+			"exit_code":      callbackPayload.ExitCode,
+			"cpu_time_ms":    cpuTimeMs,
+			"wall_time_ms":   callbackPayload.WallTimeMs,
+			"memory_kb":      memoryKB,
+		}
+		callbackPayload.CPUTimeMs = cpuTimeMs
+		callbackPayload.MemoryKB = memoryKB
+	}
+
+	// Record the delivery and apply the update together, so a replayed delivery
+	// is acknowledged without being applied twice. The update only applies if the
+	// submission can still move to this status, so a late callback never
+	// overwrites a newer one.
+	duplicate, applied := false, false
+	err = db.Transaction(func(tx *gorm.DB) error {
+		claimed, err := replay.Claim(tx, deliveryID, submission.ID)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			duplicate = true
+			return nil
+		}
+
+		// Dynamic contests award points for the time of the submission
+		if models.IsTerminalStatus(status) {
+			score, err := scoreboard.AwardedScore(tx, submission, callbackPayload.Result, callbackPayload.Score)
+			if err != nil {
+				return err
+			}
+			callbackPayload.Score = score
+			updates["score"] = score
+		}
+
+		applied, err = models.TransitionSubmission(tx, submission.ID, status, updates)
+		if err != nil || !applied {
+			return err
+		}
 
-	if err := db.Save(&submission).Error; err != nil {
+		// Keep the standings in step with the result
+		if models.IsTerminalStatus(status) && !submission.IsPractice {
+			return scoreboard.Refresh(tx, *submission.ContestID, submission.UserID, submission.ProblemID)
+		}
+		return nil
+	})
+	if err != nil {
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "failed to update submission"})
 	}
+	if duplicate {
+		log.Printf("Ignoring duplicate callback delivery %s for submission %s", deliveryID, submission.ID)
+		return c.JSON(http.StatusOK, echo.Map{
+			"message":       "duplicate delivery ignored",
+			"submission_id": submission.ID,
+		})
+	}
+	if !applied {
+		log.Printf("Ignoring %s callback for submission %s in status %s", status, submission.ID, submission.Status)
+		return c.JSON(http.StatusOK, echo.Map{
+			"message":       "stale status update ignored",
+			"submission_id": submission.ID,
+		})
+	}
+
+	if !models.IsTerminalStatus(status) {
+		// Keep the connection open for the final result
+		sse.GlobalSSEManager.PushToUser(submission.UserID.String(), callbackPayload.SubmissionID, sse.SubmissionUpdate{
+			SubmissionID: callbackPayload.SubmissionID,
+			Result:       submission.Result,
+			State:        status,
+			Status:       status,
+		})
+
+		return c.JSON(http.StatusOK, echo.Map{
+			"message":       "submission status updated successfully",
+			"submission_id": submission.ID,
+		})
+	}
+
+	// Program output on hidden tests could echo their inputs
+	if submission.HasHiddenTests {
+		callbackPayload.StdOutput = ""
+		callbackPayload.StdError = ""
+	}
 
 	// Broadcast update to SSE clients
 	sseUpdate := sse.SubmissionUpdate{
@@ -859,15 +2795,67 @@ c.Request().Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 		ExitCode:      callbackPayload.ExitCode,
 		Time:          callbackPayload.Time,
 		Memory:        callbackPayload.Memory,
+		CPUTimeMs:     callbackPayload.CPUTimeMs,
+		WallTimeMs:    callbackPayload.WallTimeMs,
+		MemoryKB:      callbackPayload.MemoryKB,
 		Message:       callbackPayload.Message,
+		State:         status,
 		Status:        "completed",
 	}
 
 	// Broadcast to the user who made the submission
 	sse.GlobalSSEManager.BroadcastToUser(submission.UserID.String(), callbackPayload.SubmissionID, sseUpdate)
 
+	if !submission.IsPractice {
+		scoreboard.Publish(db, *submission.ContestID)
+	}
+
 	return c.JSON(http.StatusOK, echo.Map{
 		"message":       "submission updated successfully",
 		"submission_id": submission.ID,
 	})
 }
+
+// Cancel a submission that has not finished judging
+func CancelSubmission(c echo.Context) error {
+	submissionID := c.Param("id")
+	db := config.DB
+
+	var submission models.Submission
+	if err := db.First(&submission, "id = ?", submissionID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "submission not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "database error"})
+	}
+
+	applied := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		applied, err = models.TransitionSubmission(tx, submission.ID, models.StatusCancelled, nil)
+		if err != nil || !applied || submission.IsPractice {
+			return err
+		}
+		return scoreboard.Refresh(tx, *submission.ContestID, submission.UserID, submission.ProblemID)
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "could not cancel submission"})
+	}
+	if !applied {
+		return c.JSON(http.StatusConflict, echo.Map{"error": "submission has already finished"})
+	}
+
+	sse.GlobalSSEManager.BroadcastToUser(submission.UserID.String(), submission.ID.String(), sse.SubmissionUpdate{
+		SubmissionID: submission.ID.String(),
+		Result:       submission.Result,
+		Message:      "Submission was cancelled",
+		State:        models.StatusCancelled,
+		Status:       "completed",
+	})
+
+	if !submission.IsPractice {
+		scoreboard.Publish(db, *submission.ContestID)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"message": "submission cancelled successfully"})
+}
@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"OJ-backend/config"
+	models "OJ-backend/models"
+	"OJ-backend/services/webhook"
+	"encoding/json"
+	"testing"
+	"time"
+
+	uuid "github.com/google/uuid"
+)
+
+// privateContest returns a private dynamic contest with every registration setting filled in
+func privateContest() models.Contest {
+	deadline := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	return models.Contest{
+		ID:                   uuid.New(),
+		Name:                 "Round 1",
+		Description:          "First round",
+		StartTime:            time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC),
+		EndTime:              time.Date(2025, 1, 1, 15, 0, 0, 0, time.UTC),
+		RegistrationDeadline: &deadline,
+		MaxParticipants:      50,
+		IsPrivate:            true,
+		InviteCode:           "s3cret",
+		ScoringMode:          models.ScoringDynamic,
+		FreezeMinutes:        60,
+		DecayPerMille:        4,
+		FloorPercent:         30,
+		WrongAttemptPenalty:  50,
+	}
+}
+
+func TestContestUpdateApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr bool
+		want    func(*models.Contest)
+	}{
+		{
+			// What the admin edit form sends
+			name: "basic fields leave registration and scoring settings alone",
+			body: `{"name":"Round 1 (rescheduled)","description":"Moved","start_time":"2025-01-02T10:00:00Z","end_time":"2025-01-02T15:00:00Z"}`,
+			want: func(c *models.Contest) {
+				c.Name = "Round 1 (rescheduled)"
+				c.Description = "Moved"
+				c.StartTime = time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)
+				c.EndTime = time.Date(2025, 1, 2, 15, 0, 0, 0, time.UTC)
+			},
+		},
+		{
+			name: "empty body changes nothing",
+			body: `{}`,
+			want: func(*models.Contest) {},
+		},
+		{
+			name: "settings that are given are applied",
+			body: `{"is_private":false,"invite_code":"","max_participants":0,"scoring_mode":"icpc","freeze_minutes":0,"floor_percent":10}`,
+			want: func(c *models.Contest) {
+				c.IsPrivate = false
+				c.InviteCode = ""
+				c.MaxParticipants = 0
+				c.ScoringMode = models.ScoringICPC
+				c.FreezeMinutes = 0
+				c.FloorPercent = 10
+			},
+		},
+		{
+			name: "an empty registration deadline removes it",
+			body: `{"registration_deadline":""}`,
+			want: func(c *models.Contest) { c.RegistrationDeadline = nil },
+		},
+		{name: "invalid start time", body: `{"start_time":"tomorrow"}`, wantErr: true},
+		{name: "invalid registration deadline", body: `{"registration_deadline":"soon"}`, wantErr: true},
+		{name: "negative capacity", body: `{"max_participants":-1}`, wantErr: true},
+		{name: "unknown scoring mode", body: `{"scoring_mode":"golf"}`, wantErr: true},
+		{name: "negative freeze", body: `{"freeze_minutes":-5}`, wantErr: true},
+		{name: "floor above 100 percent", body: `{"floor_percent":101}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body contestUpdate
+			if err := json.Unmarshal([]byte(tt.body), &body); err != nil {
+				t.Fatal(err)
+			}
+
+			contest := privateContest()
+			err := body.apply(&contest)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("apply accepted an invalid update")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("apply failed: %v", err)
+			}
+
+			want := privateContest()
+			want.ID = contest.ID
+			tt.want(&want)
+			if got, _ := json.Marshal(contest); string(got) != string(mustMarshal(t, want)) {
+				t.Errorf("contest = %s, want %s", got, mustMarshal(t, want))
+			}
+		})
+	}
+}
+
+func TestExtractSignatureFromHeader(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		keyID     string
+		signature string
+		wantErr   bool
+	}{
+		{name: "with a key ID", header: "keyid=worker-1,sha256=abc123", keyID: "worker-1", signature: "abc123"},
+		{name: "legacy header without a key ID", header: "sha256=abc123", keyID: webhook.DefaultKeyID, signature: "abc123"},
+		{name: "parts in any order with spaces", header: "sha256=abc123, keyid=worker-2", keyID: "worker-2", signature: "abc123"},
+		{name: "unknown parts are ignored", header: "v=2,sha256=abc123", keyID: webhook.DefaultKeyID, signature: "abc123"},
+		{name: "missing signature", header: "keyid=worker-1", wantErr: true},
+		{name: "empty key ID", header: "keyid=,sha256=abc123", wantErr: true},
+		{name: "bare signature", header: "abc123", wantErr: true},
+		{name: "empty header", header: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyID, signature, err := extractSignatureFromHeader(tt.header)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("extractSignatureFromHeader(%q) = (%q, %q), want an error", tt.header, keyID, signature)
+				}
+				return
+			}
+			if err != nil || keyID != tt.keyID || signature != tt.signature {
+				t.Errorf("extractSignatureFromHeader(%q) = (%q, %q, %v), want (%q, %q)", tt.header, keyID, signature, err, tt.keyID, tt.signature)
+			}
+		})
+	}
+}
+
+func TestVerifyCallbackSignature(t *testing.T) {
+	body := []byte(`{"submission_id":"42","status":"judged","result":"AC"}`)
+	signed := generateHMAC(callbackSignedContent("1700000000", "delivery-1", body), "s3cret")
+
+	tests := []struct {
+		name       string
+		timestamp  string
+		deliveryID string
+		body       []byte
+		secret     string
+		want       bool
+	}{
+		{"as signed", "1700000000", "delivery-1", body, "s3cret", true},
+		{"another key", "1700000000", "delivery-1", body, "an0ther", false},
+		{"replayed with a fresh timestamp", "1700000300", "delivery-1", body, "s3cret", false},
+		{"replayed with a new delivery ID", "1700000000", "delivery-2", body, "s3cret", false},
+		{"tampered body", "1700000000", "delivery-1", []byte(`{"submission_id":"42","status":"judged","result":"WA"}`), "s3cret", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verifyHMAC(callbackSignedContent(tt.timestamp, tt.deliveryID, tt.body), signed, tt.secret); got != tt.want {
+				t.Errorf("verifyHMAC = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTestCaseScope(t *testing.T) {
+	tests := []struct {
+		name        string
+		samplesOnly bool
+		want        string
+	}{
+		{"contestants only see samples", true, `SELECT * FROM "test_cases" WHERE is_sample = true`},
+		{"admins see every test case", false, `SELECT * FROM "test_cases"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries := recordQueries(t)
+
+			var testCases []models.TestCase
+			config.DB.Scopes(testCaseScope(tt.samplesOnly)).Find(&testCases)
+			if got := (*queries)[0]; got != tt.want {
+				t.Errorf("query = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
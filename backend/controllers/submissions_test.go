@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"OJ-backend/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	uuid "github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// queryContext returns a request context with the given query string
+func queryContext(query string) echo.Context {
+	return echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/?"+query, nil), httptest.NewRecorder())
+}
+
+func TestFilterSubmissions(t *testing.T) {
+	self := uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	other := "22222222-2222-2222-2222-222222222222"
+
+	tests := []struct {
+		name    string
+		query   string
+		userID  *uuid.UUID
+		want    []string
+		notWant []string
+		wantErr string
+	}{
+		{
+			name:    "no filters",
+			notWant: []string{"WHERE"},
+		},
+		{
+			name:  "every filter",
+			query: "user_id=" + other + "&contest_id=" + other + "&problem_id=" + other + "&language=cpp&result=WA&status=judged&from=2025-01-01T10:00:00Z&to=2025-01-01T15:00:00Z",
+			want: []string{
+				"submissions.user_id = '" + other + "'",
+				"submissions.contest_id = '" + other + "'",
+				"submissions.problem_id = '" + other + "'",
+				"submissions.language = 'cpp'",
+				"submissions.result = 'WA'",
+				"submissions.status = 'judged'",
+				"submissions.submitted_at >= '2025-01-01 10:00:00'",
+				"submissions.submitted_at < '2025-01-01 15:00:00'",
+			},
+		},
+		{
+			name:    "a user's own list ignores user_id",
+			query:   "user_id=" + other,
+			userID:  &self,
+			want:    []string{"submissions.user_id = '" + self.String() + "'"},
+			notWant: []string{other},
+		},
+		{name: "invalid user_id", query: "user_id=alice", wantErr: "invalid user_id"},
+		{name: "invalid contest_id", query: "contest_id=42", wantErr: "invalid contest_id"},
+		{name: "invalid status", query: "status=done", wantErr: "invalid status"},
+		{name: "invalid from", query: "from=yesterday", wantErr: "invalid from format"},
+		{name: "invalid to", query: "to=2025-01-01", wantErr: "invalid to format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries := recordQueries(t)
+
+			query, err := filterSubmissions(queryContext(tt.query), config.DB.Table("submissions"), tt.userID)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("filterSubmissions error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var total int64
+			query.Count(&total)
+			sql := (*queries)[0]
+			for _, want := range tt.want {
+				if !strings.Contains(sql, want) {
+					t.Errorf("query %s\ndoes not contain %s", sql, want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(sql, notWant) {
+					t.Errorf("query %s\ncontains %s", sql, notWant)
+				}
+			}
+		})
+	}
+}
+
+func TestParsePagination(t *testing.T) {
+	tests := []struct {
+		query   string
+		page    int
+		perPage int
+		wantErr bool
+	}{
+		{"", 1, defaultPerPage, false},
+		{"page=3&per_page=50", 3, 50, false},
+		{"per_page=100", 1, 100, false},
+		{"page=0", 0, 0, true},
+		{"page=two", 0, 0, true},
+		{"per_page=0", 0, 0, true},
+		{"per_page=101", 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			page, perPage, err := parsePagination(queryContext(tt.query))
+			if (err != nil) != tt.wantErr || page != tt.page || perPage != tt.perPage {
+				t.Errorf("parsePagination(%q) = (%d, %d, %v), want (%d, %d, error %v)", tt.query, page, perPage, err, tt.page, tt.perPage, tt.wantErr)
+			}
+		})
+	}
+}
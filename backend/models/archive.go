@@ -0,0 +1,141 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DefaultProblemPoints is the most a contest problem is worth unless the contest says otherwise
+const DefaultProblemPoints = 100
+
+// colorPattern matches the #rrggbb colours of contest problems
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// IsValidColor reports whether color is empty or a #rrggbb colour
+func IsValidColor(color string) bool {
+	return color == "" || colorPattern.MatchString(color)
+}
+
+// ContestProblem is the contest_problems join table, linking archive problems
+// to the contests that use them
+type ContestProblem struct {
+	ContestID  uuid.UUID `json:"contest_id" gorm:"primaryKey;uniqueIndex:idx_contest_problems_label"`
+	ProblemID  uuid.UUID `json:"problem_id" gorm:"primaryKey"`
+	Label      string    `json:"label" gorm:"not null;uniqueIndex:idx_contest_problems_label"` // Short name of the problem in this contest, e.g. "A"
+	OrderIndex int       `json:"order_index" gorm:"not null;default:0"`                        // Position of the problem in the contest's lists and scoreboard
+	Points     int       `json:"points" gorm:"not null;default:100"`                           // Most the problem is worth in this contest; judge scores are scaled to it
+	Color      string    `json:"color"`                                                        // Balloon colour as #rrggbb, empty for none
+	CreatedAt  time.Time `json:"created_at" gorm:"autoCreateTime"`
+
+	Problem Problem `json:"-" gorm:"foreignKey:ProblemID"`
+}
+
+// ContestProblemView is a problem as it appears in one contest
+type ContestProblemView struct {
+	Problem
+	ContestID  uuid.UUID `json:"contest_id"`
+	Label      string    `json:"label"`
+	OrderIndex int       `json:"order_index"`
+	Points     int       `json:"points"`
+	Color      string    `json:"color"`
+}
+
+// View returns the linked problem with its metadata in the contest
+func (cp ContestProblem) View() ContestProblemView {
+	return ContestProblemView{
+		Problem:    cp.Problem,
+		ContestID:  cp.ContestID,
+		Label:      cp.Label,
+		OrderIndex: cp.OrderIndex,
+		Points:     cp.Points,
+		Color:      cp.Color,
+	}
+}
+
+// JudgeMaxScore is the score the judge gives a fully accepted submission
+const JudgeMaxScore = 100
+
+// ScaleScore converts a judge score to the problem's points in the contest
+func (cp ContestProblem) ScaleScore(score int) int {
+	return score * cp.Points / JudgeMaxScore
+}
+
+// ContestProblemOrder is the order in which contest problems are listed
+const ContestProblemOrder = "contest_problems.order_index ASC, contest_problems.label ASC"
+
+// ProblemLabel returns the default label of the problem at the given zero-based
+// position in a contest: A to Z, then P27, P28 and so on
+func ProblemLabel(index int) string {
+	if index < 26 {
+		return string(rune('A' + index))
+	}
+	return fmt.Sprintf("P%d", index+1)
+}
+
+// Tag marks archive problems by topic, such as "dp" or "graphs"
+type Tag struct {
+	ID   uuid.UUID `json:"id" gorm:"primaryKey"`
+	Name string    `json:"name" gorm:"not null;uniqueIndex"`
+}
+
+// NormalizeTags lowercases and trims tag names, dropping blanks and duplicates
+func NormalizeTags(names []string) []string {
+	normalized := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		normalized = append(normalized, name)
+	}
+	return normalized
+}
+
+// ProblemSummary is an archive problem together with how many users solved it
+type ProblemSummary struct {
+	Problem
+	SolveCount int64 `json:"solve_count"`
+}
+
+// ProblemSearchVector is the full-text document of a problem: its title and
+// statement. Queries must use the same expression for the index to apply.
+const ProblemSearchVector = "to_tsvector('english', title || ' ' || coalesce(description, ''))"
+
+// MigrateProblemSearch creates the index behind full-text search of the archive
+func MigrateProblemSearch(db *gorm.DB) error {
+	return db.Exec("CREATE INDEX IF NOT EXISTS idx_problems_search ON problems USING GIN (" + ProblemSearchVector + ")").Error
+}
+
+// MigrateContestProblems moves problems that still belong to a single contest
+// through problems.contest_id into contest_problems, then drops the column
+func MigrateContestProblems(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&Problem{}, "contest_id") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`
+			INSERT INTO contest_problems (contest_id, problem_id, label, order_index, points, created_at)
+			SELECT contest_id, id,
+				CASE WHEN position <= 26 THEN chr(64 + position::int) ELSE 'P' || position END,
+				position - 1, ?, created_at
+			FROM (
+				SELECT contest_id, id, created_at,
+					ROW_NUMBER() OVER (PARTITION BY contest_id ORDER BY created_at) AS position
+				FROM problems
+				WHERE contest_id IS NOT NULL
+			) AS numbered
+			ON CONFLICT DO NOTHING`, DefaultProblemPoints).Error; err != nil {
+			return err
+		}
+
+		return tx.Migrator().DropColumn(&Problem{}, "contest_id")
+	})
+}
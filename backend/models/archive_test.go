@@ -0,0 +1,93 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProblemLabel(t *testing.T) {
+	tests := []struct {
+		index int
+		want  string
+	}{
+		{0, "A"},
+		{1, "B"},
+		{25, "Z"},
+		{26, "P27"},
+		{99, "P100"},
+	}
+
+	for _, tt := range tests {
+		if got := ProblemLabel(tt.index); got != tt.want {
+			t.Errorf("ProblemLabel(%d) = %q, want %q", tt.index, got, tt.want)
+		}
+	}
+}
+
+func TestIsValidColor(t *testing.T) {
+	tests := []struct {
+		color string
+		want  bool
+	}{
+		{"", true},
+		{"#ff8800", true},
+		{"#FF8800", true},
+		{"ff8800", false},
+		{"#f80", false},
+		{"#ff88001", false},
+		{"#gg8800", false},
+		{"red", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsValidColor(tt.color); got != tt.want {
+			t.Errorf("IsValidColor(%q) = %v, want %v", tt.color, got, tt.want)
+		}
+	}
+}
+
+func TestScaleScore(t *testing.T) {
+	tests := []struct {
+		name   string
+		points int
+		score  int
+		want   int
+	}{
+		{"default points", DefaultProblemPoints, 100, 100},
+		{"full score on a bigger problem", 500, JudgeMaxScore, 500},
+		{"partial score", 500, 40, 200},
+		{"rounded down", 30, 50, 15},
+		{"rejected", 500, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (ContestProblem{Points: tt.points}).ScaleScore(tt.score); got != tt.want {
+				t.Errorf("ScaleScore(%d) with %d points = %d, want %d", tt.score, tt.points, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		name  string
+		names []string
+		want  []string
+	}{
+		{"nothing", nil, []string{}},
+		{"lowercased and trimmed", []string{" DP ", "Graphs"}, []string{"dp", "graphs"}},
+		{"duplicates after normalizing", []string{"dp", "DP", " dp"}, []string{"dp"}},
+		{"blanks dropped", []string{"", "  ", "math"}, []string{"math"}},
+		{"order kept", []string{"strings", "dp", "greedy"}, []string{"strings", "dp", "greedy"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NormalizeTags(tt.names)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") || got == nil {
+				t.Errorf("NormalizeTags(%q) = %q, want %q", tt.names, got, tt.want)
+			}
+		})
+	}
+}
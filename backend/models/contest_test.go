@@ -0,0 +1,61 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPhase(t *testing.T) {
+	start := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	contest := Contest{StartTime: start, EndTime: start.Add(5 * time.Hour)}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want string
+	}{
+		{"before the start", start.Add(-time.Second), ContestUpcoming},
+		{"at the start", start, ContestRunning},
+		{"just before the end", contest.EndTime.Add(-time.Second), ContestRunning},
+		{"at the end", contest.EndTime, ContestEnded},
+		{"after the end", contest.EndTime.Add(time.Hour), ContestEnded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := contest.Phase(tt.now); got != tt.want {
+				t.Errorf("Phase = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistrationOpen(t *testing.T) {
+	start := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	deadline := start.Add(-time.Hour)
+	lateDeadline := start.Add(time.Hour)
+
+	tests := []struct {
+		name     string
+		deadline *time.Time
+		now      time.Time
+		want     bool
+	}{
+		{"before the contest without a deadline", nil, start.Add(-2 * time.Hour), true},
+		{"late registration while running", nil, start.Add(time.Hour), true},
+		{"after the contest", nil, start.Add(5 * time.Hour), false},
+		{"before the deadline", &deadline, deadline.Add(-time.Minute), true},
+		{"at the deadline", &deadline, deadline, false},
+		{"after the deadline but before the start", &deadline, start.Add(-time.Minute), false},
+		{"deadline during the contest", &lateDeadline, start.Add(30 * time.Minute), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contest := Contest{StartTime: start, EndTime: start.Add(5 * time.Hour), RegistrationDeadline: tt.deadline}
+			if got := contest.RegistrationOpen(tt.now); got != tt.want {
+				t.Errorf("RegistrationOpen = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
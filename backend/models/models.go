@@ -2,6 +2,7 @@ package model
 
 import (
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 	"time"
 )
 
@@ -26,50 +27,218 @@ type Contest struct {
 	EndTime     time.Time `json:"end_time" gorm:"not null"`
 	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
 
-	Problems []Problem `json:"problems" gorm:"foreignKey:ContestID"`
+	RegistrationDeadline *time.Time `json:"registration_deadline"`                      // Registration closes at the deadline, or at the end of the contest if unset
+	MaxParticipants      int        `json:"max_participants" gorm:"not null;default:0"` // 0 means unlimited
+	IsPrivate            bool       `json:"is_private" gorm:"not null;default:false"`   // Joining needs the invite code or an admin-approved request
+	InviteCode           string     `json:"invite_code,omitempty"`
+	ScoringMode          string     `json:"scoring_mode" gorm:"not null;default:icpc"` // How the leaderboard ranks participants, see scoreboard.go
+	FreezeMinutes        int        `json:"freeze_minutes" gorm:"not null;default:0"`  // Length of the scoreboard freeze before the end, 0 disables it
+	UnfrozenAt           *time.Time `json:"unfrozen_at"`                               // Set once an admin lifts the freeze after the contest
+
+	// Dynamic scoring settings, see DynamicScore
+	DecayPerMille       int `json:"decay_per_mille" gorm:"not null;default:0"`       // Thousandths of a problem's points lost per minute from the start
+	FloorPercent        int `json:"floor_percent" gorm:"not null;default:0"`         // Least a solve is worth, as a percentage of the problem's points
+	WrongAttemptPenalty int `json:"wrong_attempt_penalty" gorm:"not null;default:0"` // Points lost for each rejected attempt before the solve
+
+	Problems []Problem `json:"problems" gorm:"many2many:contest_problems;"`
 	Users    []User    `json:"users" gorm:"many2many:contest_users;"`
 }
 
+// Contest phases, derived from the contest's start and end time
+const (
+	ContestUpcoming = "upcoming" // Problems are hidden and submissions are rejected
+	ContestRunning  = "running"  // Submissions are judged and ranked
+	ContestEnded    = "ended"    // Submissions are judged as practice and not ranked
+)
+
+// Phase returns the phase of the contest at the given time
+func (c Contest) Phase(now time.Time) string {
+	switch {
+	case now.Before(c.StartTime):
+		return ContestUpcoming
+	case now.Before(c.EndTime):
+		return ContestRunning
+	default:
+		return ContestEnded
+	}
+}
+
+// ForPublic returns a copy of the contest without its invite code
+func (c Contest) ForPublic() Contest {
+	c.InviteCode = ""
+	return c
+}
+
+// RegistrationOpen reports whether users may still register at the given time
+func (c Contest) RegistrationOpen(now time.Time) bool {
+	if c.RegistrationDeadline != nil && !now.Before(*c.RegistrationDeadline) {
+		return false
+	}
+	return c.Phase(now) != ContestEnded
+}
+
+// Participant statuses of a contest_users row
+const (
+	ParticipantRegistered   = "registered"   // May submit and is ranked
+	ParticipantPending      = "pending"      // Asked to join a private contest and awaits admin approval
+	ParticipantDisqualified = "disqualified" // Removed from ranking and may not submit
+)
+
+// IsValidParticipantStatus reports whether status is a known participant status
+func IsValidParticipantStatus(status string) bool {
+	switch status {
+	case ParticipantRegistered, ParticipantPending, ParticipantDisqualified:
+		return true
+	}
+	return false
+}
+
+// FreezeTime returns when the public scoreboard freezes, or nil if the contest has no freeze
+func (c Contest) FreezeTime() *time.Time {
+	if c.FreezeMinutes <= 0 {
+		return nil
+	}
+	freezeAt := c.EndTime.Add(-time.Duration(c.FreezeMinutes) * time.Minute)
+	return &freezeAt
+}
+
+// IsFrozen reports whether the public scoreboard is frozen at the given time
+func (c Contest) IsFrozen(now time.Time) bool {
+	freezeAt := c.FreezeTime()
+	return freezeAt != nil && !now.Before(*freezeAt) && c.UnfrozenAt == nil
+}
+
+// ContestView is a contest together with its phase at the time of the request
+type ContestView struct {
+	Contest
+	Status string `json:"status"` // upcoming, running or ended
+}
+
+// ContestDetail is a contest as seen by one user
+type ContestDetail struct {
+	ContestView
+	ServerTime         time.Time             `json:"server_time"`
+	RegistrationStatus string                `json:"registration_status"` // The user's participant status, empty if they have not registered
+	Problems           []ContestProblemState `json:"problems"`            // Empty until the contest starts
+}
+
+// ContestProblemState is a contest problem with the user's progress on it
+type ContestProblemState struct {
+	ID        uuid.UUID `json:"id"`
+	Label     string    `json:"label"`
+	Title     string    `json:"title"`
+	Points    int       `json:"points"`
+	Color     string    `json:"color"`
+	Attempted bool      `json:"attempted"`
+	Solved    bool      `json:"solved"`
+}
+
+// ContestUser is the contest_users join table, listing the participants of a contest
+type ContestUser struct {
+	ContestID    uuid.UUID `json:"contest_id" gorm:"primaryKey"`
+	UserID       uuid.UUID `json:"user_id" gorm:"primaryKey"`
+	Status       string    `json:"status" gorm:"not null;default:registered;index"`
+	RegisteredAt time.Time `json:"registered_at" gorm:"autoCreateTime"`
+	UpdatedAt    time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
 type Problem struct {
 	ID          uuid.UUID `json:"id" gorm:"primaryKey"`
-	ContestID   uuid.UUID `json:"contest_id" gorm:"not null"`
 	Title       string    `json:"title" gorm:"not null"`
 	Description string    `json:"description"`
+	IsPublic    bool      `json:"is_public" gorm:"not null;default:false"`    // Listed in the archive on its own, without having been in a contest
+	Difficulty  int       `json:"difficulty" gorm:"not null;default:0;index"` // Difficulty rating, 0 when unrated
+	Source      string    `json:"source"`                                     // Where the problem comes from or who wrote it
 	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
 
+	Tags        []Tag        `json:"tags,omitempty" gorm:"many2many:problem_tags;"`
+	Contests    []Contest    `json:"contests,omitempty" gorm:"many2many:contest_problems;"`
 	Submissions []Submission `json:"submissions" gorm:"foreignKey:ProblemID"`
 	Tests       []TestCase   `json:"tests" gorm:"foreignKey:ProblemID"`
 }
 
 type Submission struct {
-	ID             uuid.UUID `json:"id" gorm:"primaryKey"`
-	ProblemID      uuid.UUID `json:"problem_id" gorm:"not null"`
-	UserID         uuid.UUID `json:"user_id" gorm:"not null"`
-	ContestID      uuid.UUID `json:"contest_id" gorm:"not null"`
-	SubmittedAt    time.Time `json:"submitted_at" gorm:"autoCreateTime"`
-	Result         string    `json:"result" gorm:"not null"`   // e.g., "AC", "WA", "pending"
-	Language       string    `json:"language" gorm:"not null"` // Programming language used for the submission
-	SourceCode     string    `json:"source_code" gorm:"not null"`
-	Score          int       `json:"score" gorm:"default:0"`
-	StdInput       string    `json:"std_input"`
-	ExpectedOutput string    `json:"expected_output"`
-	StdOutput      string    `json:"std_output"`
-	StdError       string    `json:"std_error"`
-	CreatedAt      time.Time `json:"created_at" gorm:"autoCreateTime"`
-	CompileOutput  string    `json:"compile_output"` // Output from the compilation process
-	ExitSignal     int       `json:"exit_signal"`    // Exit signal from the execution of the code
-	ExitCode       int       `json:"exit_code"`      // Exit code from the execution of the code
-	CallbackURL    string    `json:"callback_url"`   // URL to send the result of the submission
+	ID             uuid.UUID  `json:"id" gorm:"primaryKey"`
+	ProblemID      uuid.UUID  `json:"problem_id" gorm:"not null"`
+	UserID         uuid.UUID  `json:"user_id" gorm:"not null"`
+	ContestID      *uuid.UUID `json:"contest_id" gorm:"index"` // Contest the submission was made in, nil for archive practice
+	SubmittedAt    time.Time  `json:"submitted_at" gorm:"autoCreateTime"`
+	Status         string     `json:"status" gorm:"not null;default:queued;index"` // Lifecycle status, see status.go
+	Result         string     `json:"result" gorm:"not null"`                      // e.g., "AC", "WA", "pending"
+	Language       string     `json:"language" gorm:"not null"`                    // Programming language used for the submission
+	SourceCode     string     `json:"source_code" gorm:"not null"`
+	Score          int        `json:"score" gorm:"default:0"`
+	StdInput       string     `json:"-"` // All test inputs, never returned by the API
+	ExpectedOutput string     `json:"-"` // All expected outputs, never returned by the API
+	StdOutput      string     `json:"std_output"`
+	StdError       string     `json:"std_error"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	CompileOutput  string     `json:"compile_output"`                            // Output from the compilation process
+	ExitSignal     int        `json:"exit_signal"`                               // Exit signal from the execution of the code
+	ExitCode       int        `json:"exit_code"`                                 // Exit code from the execution of the code
+	CallbackURL    string     `json:"callback_url"`                              // URL to send the result of the submission
+	CPUTimeMs      int        `json:"cpu_time_ms"`                               // CPU time used by the run in milliseconds
+	WallTimeMs     int        `json:"wall_time_ms"`                              // Wall clock time used by the run in milliseconds
+	MemoryKB       int        `json:"memory_kb"`                                 // Peak memory used by the run in KB
+	HasHiddenTests bool       `json:"has_hidden_tests"`                          // Whether the run included hidden test cases
+	IsPractice     bool       `json:"is_practice" gorm:"not null;default:false"` // Submitted outside a running contest, not ranked
+
+	PublishAttempts int        `json:"publish_attempts" gorm:"default:0"` // Number of times the submission was sent to the queue
+	LastPublishedAt *time.Time `json:"last_published_at"`                 // When the submission was last sent to the queue
+
+	QueuedAt    *time.Time `json:"queued_at"`    // When the submission was last queued for judging
+	CompilingAt *time.Time `json:"compiling_at"` // When a worker started compiling the submission
+	RunningAt   *time.Time `json:"running_at"`   // When a worker started running the submission
+	FinishedAt  *time.Time `json:"finished_at"`  // When the submission was judged, cancelled or failed
 
 	Problem Problem `json:"problem" gorm:"foreignKey:ProblemID"`
 	User    User    `json:"user" gorm:"foreignKey:UserID"`
 }
 
+// ForContestant returns a copy of the submission that is safe to show to its
+// author. The program's output is dropped when it ran on hidden tests, since
+// it could echo their inputs.
+func (s Submission) ForContestant() Submission {
+	if s.HasHiddenTests {
+		s.StdOutput = ""
+		s.StdError = ""
+	}
+	return s
+}
+
+// OutboxMessage is a queue message written in the same transaction as the
+// change that produced it and published later by the outbox relay
+type OutboxMessage struct {
+	ID           uuid.UUID  `json:"id" gorm:"primaryKey"`
+	SubmissionID uuid.UUID  `json:"submission_id" gorm:"not null;index"`
+	Payload      string     `json:"payload" gorm:"not null"` // JSON encoded RabbitMQPayload
+	Attempts     int        `json:"attempts" gorm:"default:0"`
+	LastError    string     `json:"last_error"`
+	CreatedAt    time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	ClaimedAt    *time.Time `json:"claimed_at"`           // Set while a relay publishes the message
+	SentAt       *time.Time `json:"sent_at" gorm:"index"` // Set once the broker confirmed the message
+}
+
+// MigrateOutbox creates the partial index the outbox relay claims unsent
+// messages from, so claiming stays cheap however many sent messages are kept
+func MigrateOutbox(db *gorm.DB) error {
+	return db.Exec("CREATE INDEX IF NOT EXISTS idx_outbox_messages_unsent ON outbox_messages (created_at) WHERE sent_at IS NULL").Error
+}
+
+// CallbackDelivery records a worker callback that has been applied so that
+// replays of the same delivery are recognised
+type CallbackDelivery struct {
+	ID           string    `json:"id" gorm:"primaryKey"` // Delivery ID sent by the worker
+	SubmissionID uuid.UUID `json:"submission_id" gorm:"not null"`
+	ReceivedAt   time.Time `json:"received_at" gorm:"not null;index"`
+}
+
 type TestCase struct {
 	ID        uuid.UUID `json:"id" gorm:"primaryKey"`
 	ProblemID uuid.UUID `json:"problem_id" gorm:"not null"`
-	Input     string    `json:"input" gorm:"not null"`  // Input for the test case
-	Output    string    `json:"output" gorm:"not null"` // Expected output for the test case
+	Input     string    `json:"input" gorm:"not null"`                   // Input for the test case
+	Output    string    `json:"output" gorm:"not null"`                  // Expected output for the test case
+	IsSample  bool      `json:"is_sample" gorm:"not null;default:false"` // Sample tests are shown to contestants, the rest are hidden
 	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
 
 	Problem Problem `json:"problem" gorm:"foreignKey:ProblemID"`
@@ -81,18 +250,48 @@ type Language struct {
 	CompileCommand string `json:"compile_command" gorm:"not null"` // Command to compile the code
 	RunCommand     string `json:"run_command" gorm:"not null"`     // Command to run
 	TimeLimit      int    `json:"time_limit" gorm:"not null"`      // Time limit for the submission in milliseconds
-	MemoryLimit    int    `json:"memory_limit"`    // Memory limit for the submission in MB
-	WallLimit      int    `json:"wall_limit"`      // Wall time limit for the submission in seconds
-	StackLimit     int    `json:"stack_limit"`     // Stack limit for the submission in MB
-	OutputLimit    int    `json:"output_limit"`    // Output limit for the submission in MB
+	MemoryLimit    int    `json:"memory_limit"`                    // Memory limit for the submission in MB
+	WallLimit      int    `json:"wall_limit"`                      // Wall time limit for the submission in seconds
+	StackLimit     int    `json:"stack_limit"`                     // Stack limit for the submission in MB
+	OutputLimit    int    `json:"output_limit"`                    // Output limit for the submission in MB
 	SrcFile        string `json:"src_file" gorm:"not null"`        // Source file name for the submission
 }
 
-type LeaderboardEntry struct {
-	UserID          uuid.UUID `json:"user_id"`
-	Username        string    `json:"username"`
-	TotalScore      int       `json:"total_score"`
-	FirstSubmission time.Time `json:"first_submission"`
+type ParticipantEntry struct {
+	UserID       uuid.UUID `json:"user_id"`
+	Username     string    `json:"username"`
+	Email        string    `json:"email"`
+	Status       string    `json:"status"`
+	RegisteredAt time.Time `json:"registered_at"`
+}
+
+// SubmissionSummary is a submission as shown in listings, without its source code or outputs
+type SubmissionSummary struct {
+	ID           uuid.UUID  `json:"id"`
+	ProblemID    uuid.UUID  `json:"problem_id"`
+	ProblemTitle string     `json:"problem_title"`
+	UserID       uuid.UUID  `json:"user_id"`
+	Username     string     `json:"username"`
+	ContestID    *uuid.UUID `json:"contest_id"`
+	SubmittedAt  time.Time  `json:"submitted_at"`
+	Status       string     `json:"status"`
+	Result       string     `json:"result"`
+	Language     string     `json:"language"`
+	Score        int        `json:"score"`
+	CPUTimeMs    int        `json:"cpu_time_ms"`
+	MemoryKB     int        `json:"memory_kb"`
+	IsPractice   bool       `json:"is_practice"`
+}
+
+type FastestSubmissionEntry struct {
+	SubmissionID uuid.UUID `json:"submission_id"`
+	UserID       uuid.UUID `json:"user_id"`
+	Username     string    `json:"username"`
+	Language     string    `json:"language"`
+	CPUTimeMs    int       `json:"cpu_time_ms"`
+	WallTimeMs   int       `json:"wall_time_ms"`
+	MemoryKB     int       `json:"memory_kb"`
+	SubmittedAt  time.Time `json:"submitted_at"`
 }
 
 type RabbitMQPayload struct {
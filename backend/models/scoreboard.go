@@ -0,0 +1,190 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Contest scoring modes
+const (
+	// ScoringICPC ranks by problems solved, then by penalty time
+	ScoringICPC = "icpc"
+	// ScoringIOI ranks by the sum of each participant's best score per problem
+	ScoringIOI = "ioi"
+	// ScoringDynamic ranks like IOI, but an accepted submission is worth less
+	// the later it comes and the more rejected attempts precede it
+	ScoringDynamic = "dynamic"
+)
+
+// IsValidScoringMode reports whether mode is a known scoring mode
+func IsValidScoringMode(mode string) bool {
+	switch mode {
+	case ScoringICPC, ScoringIOI, ScoringDynamic:
+		return true
+	}
+	return false
+}
+
+// Defaults of the dynamic scoring settings, as in Codeforces rounds
+const (
+	DefaultDecayPerMille       = 4
+	DefaultFloorPercent        = 30
+	DefaultWrongAttemptPenalty = 50
+)
+
+// DynamicScore returns what an accepted submission made at submittedAt is worth
+// on a problem with the given points, after wrongAttempts rejected attempts
+func (c Contest) DynamicScore(points int, submittedAt time.Time, wrongAttempts int) int {
+	minutes := max(int(submittedAt.Sub(c.StartTime).Minutes()), 0)
+
+	score := points - points*c.DecayPerMille*minutes/1000 - wrongAttempts*c.WrongAttemptPenalty
+	floor := points * c.FloorPercent / 100
+
+	return max(score, floor)
+}
+
+// Scoreboard is the ranked standings of a contest
+type Scoreboard struct {
+	ContestID   uuid.UUID           `json:"contest_id"`
+	ScoringMode string              `json:"scoring_mode"`
+	Problems    []ScoreboardProblem `json:"problems"` // Column order of each entry's problem results
+	Entries     []LeaderboardEntry  `json:"entries"`
+	Frozen      bool                `json:"frozen"`              // Attempts from FrozenAt on are only shown as pending
+	FrozenAt    *time.Time          `json:"frozen_at,omitempty"` // Set while Frozen
+}
+
+type ScoreboardProblem struct {
+	ID     uuid.UUID `json:"id"`
+	Label  string    `json:"label"`
+	Title  string    `json:"title"`
+	Points int       `json:"points"` // Most the problem is worth, IOI and dynamic only
+	Color  string    `json:"color"`
+}
+
+// LeaderboardEntry is one participant's row on the scoreboard
+type LeaderboardEntry struct {
+	Rank       int             `json:"rank"` // Participants with equal results share a rank
+	UserID     uuid.UUID       `json:"user_id"`
+	Username   string          `json:"username"`
+	Solved     int             `json:"solved"`
+	Penalty    int             `json:"penalty"`     // Penalty minutes, ICPC only
+	TotalScore int             `json:"total_score"` // Sum of the best score per problem, IOI and dynamic only
+	Problems   []ProblemResult `json:"problems"`
+}
+
+// ProblemResult is one cell of the per-problem attempt/solve matrix
+type ProblemResult struct {
+	ProblemID       uuid.UUID  `json:"problem_id"`
+	Attempts        int        `json:"attempts"` // Counted attempts, up to and including the first accepted one
+	Solved          bool       `json:"solved"`
+	SolvedAt        *time.Time `json:"solved_at,omitempty"` // When the first accepted attempt was submitted
+	SolvedAtMinutes int        `json:"solved_at_minutes"`   // Minutes from contest start to the first accepted attempt
+	Penalty         int        `json:"penalty"`             // Penalty minutes the problem adds when solved
+	FirstToSolve    bool       `json:"first_to_solve"`
+	Score           int        `json:"score"`   // Best score of any attempt in the problem's points, IOI and dynamic only
+	Pending         int        `json:"pending"` // Attempts whose verdict is not shown yet, because of the freeze or because they are still being judged
+}
+
+// ResolverEvent reveals the pending attempts of one scoreboard cell. Replaying
+// the events in order turns the frozen scoreboard into the final one.
+type ResolverEvent struct {
+	Step       int           `json:"step"`
+	UserID     uuid.UUID     `json:"user_id"`
+	Username   string        `json:"username"`
+	Result     ProblemResult `json:"result"` // The revealed cell
+	RankBefore int           `json:"rank_before"`
+	RankAfter  int           `json:"rank_after"`
+}
+
+// ResolverData is everything a resolver ceremony needs: the frozen scoreboard,
+// the reveal events and the final scoreboard they lead to
+type ResolverData struct {
+	Frozen Scoreboard      `json:"frozen"`
+	Events []ResolverEvent `json:"events"`
+	Final  Scoreboard      `json:"final"`
+}
+
+// Standing is the materialized result of one participant on one problem of a
+// contest, refreshed whenever one of their submissions changes. The Frozen
+// columns hold the same result as the public sees it during the freeze.
+type Standing struct {
+	ContestID uuid.UUID `json:"contest_id" gorm:"primaryKey"`
+	UserID    uuid.UUID `json:"user_id" gorm:"primaryKey"`
+	ProblemID uuid.UUID `json:"problem_id" gorm:"primaryKey"`
+
+	Attempts        int        `json:"attempts" gorm:"not null;default:0"`
+	Solved          bool       `json:"solved" gorm:"not null;default:false"`
+	SolvedAt        *time.Time `json:"solved_at"`
+	SolvedAtMinutes int        `json:"solved_at_minutes" gorm:"not null;default:0"`
+	Penalty         int        `json:"penalty" gorm:"not null;default:0"`
+	Score           int        `json:"score" gorm:"not null;default:0"`
+	Pending         int        `json:"pending" gorm:"not null;default:0"`
+
+	FrozenAttempts        int        `json:"frozen_attempts" gorm:"not null;default:0"`
+	FrozenSolved          bool       `json:"frozen_solved" gorm:"not null;default:false"`
+	FrozenSolvedAt        *time.Time `json:"frozen_solved_at"`
+	FrozenSolvedAtMinutes int        `json:"frozen_solved_at_minutes" gorm:"not null;default:0"`
+	FrozenPenalty         int        `json:"frozen_penalty" gorm:"not null;default:0"`
+	FrozenScore           int        `json:"frozen_score" gorm:"not null;default:0"`
+	FrozenPending         int        `json:"frozen_pending" gorm:"not null;default:0"`
+
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// SetResults stores the live and frozen results of the standing's cell
+func (s *Standing) SetResults(live, frozen ProblemResult) {
+	s.Attempts, s.Solved, s.SolvedAt, s.SolvedAtMinutes = live.Attempts, live.Solved, live.SolvedAt, live.SolvedAtMinutes
+	s.Penalty, s.Score, s.Pending = live.Penalty, live.Score, live.Pending
+
+	s.FrozenAttempts, s.FrozenSolved, s.FrozenSolvedAt, s.FrozenSolvedAtMinutes = frozen.Attempts, frozen.Solved, frozen.SolvedAt, frozen.SolvedAtMinutes
+	s.FrozenPenalty, s.FrozenScore, s.FrozenPending = frozen.Penalty, frozen.Score, frozen.Pending
+}
+
+// Result returns the standing's cell, live or as the public sees it during the freeze
+func (s Standing) Result(frozen bool) ProblemResult {
+	if frozen {
+		return ProblemResult{
+			ProblemID:       s.ProblemID,
+			Attempts:        s.FrozenAttempts,
+			Solved:          s.FrozenSolved,
+			SolvedAt:        s.FrozenSolvedAt,
+			SolvedAtMinutes: s.FrozenSolvedAtMinutes,
+			Penalty:         s.FrozenPenalty,
+			Score:           s.FrozenScore,
+			Pending:         s.FrozenPending,
+		}
+	}
+
+	return ProblemResult{
+		ProblemID:       s.ProblemID,
+		Attempts:        s.Attempts,
+		Solved:          s.Solved,
+		SolvedAt:        s.SolvedAt,
+		SolvedAtMinutes: s.SolvedAtMinutes,
+		Penalty:         s.Penalty,
+		Score:           s.Score,
+		Pending:         s.Pending,
+	}
+}
+
+// RankChange is a point in a participant's standings history, recorded
+// whenever a judged submission changes their rank or results
+type RankChange struct {
+	At         time.Time `json:"at"`
+	Minute     int       `json:"minute"` // Minutes from contest start
+	UserID     uuid.UUID `json:"user_id"`
+	Username   string    `json:"username"`
+	Rank       int       `json:"rank"`
+	Solved     int       `json:"solved"`
+	Penalty    int       `json:"penalty"`
+	TotalScore int       `json:"total_score"`
+}
+
+// StandingsHistory is the series of rank changes of a contest, starting with
+// every participant tied at the contest start
+type StandingsHistory struct {
+	ContestID   uuid.UUID    `json:"contest_id"`
+	ScoringMode string       `json:"scoring_mode"`
+	Changes     []RankChange `json:"changes"`
+}
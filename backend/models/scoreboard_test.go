@@ -0,0 +1,42 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDynamicScore(t *testing.T) {
+	start := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	contest := Contest{
+		StartTime:           start,
+		DecayPerMille:       4,
+		FloorPercent:        30,
+		WrongAttemptPenalty: 50,
+	}
+
+	tests := []struct {
+		name          string
+		contest       Contest
+		points        int
+		submittedAt   time.Time
+		wrongAttempts int
+		want          int
+	}{
+		{"solved at the start", contest, 1000, start, 0, 1000},
+		{"decays per whole minute", contest, 1000, start.Add(10*time.Minute + 59*time.Second), 0, 960},
+		{"wrong attempts cost points", contest, 1000, start.Add(10 * time.Minute), 2, 860},
+		{"never below the floor", contest, 1000, start.Add(200 * time.Minute), 0, 300},
+		{"wrong attempts stop at the floor", contest, 1000, start, 20, 300},
+		{"submitted before the start does not gain points", contest, 1000, start.Add(-5 * time.Minute), 0, 1000},
+		{"no decay without settings", Contest{StartTime: start}, 500, start.Add(90 * time.Minute), 3, 500},
+		{"zero points", contest, 0, start.Add(10 * time.Minute), 1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.contest.DynamicScore(tt.points, tt.submittedAt, tt.wrongAttempts); got != tt.want {
+				t.Errorf("DynamicScore(%d, %s, %d) = %d, want %d", tt.points, tt.submittedAt.Sub(start), tt.wrongAttempts, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Submission lifecycle statuses. A submission starts out queued, moves through
+// compiling and running while a worker judges it, and ends up judged,
+// cancelled or failed.
+const (
+	StatusQueued    = "queued"
+	StatusCompiling = "compiling"
+	StatusRunning   = "running"
+	StatusJudged    = "judged"
+	StatusCancelled = "cancelled"
+	StatusFailed    = "failed"
+)
+
+// submissionTransitions lists the statuses each status may move to. Workers may
+// skip intermediate statuses, and a compiling or running submission may go back
+// to queued when it is requeued after its worker was lost.
+var submissionTransitions = map[string][]string{
+	StatusQueued:    {StatusCompiling, StatusRunning, StatusJudged, StatusCancelled, StatusFailed},
+	StatusCompiling: {StatusQueued, StatusRunning, StatusJudged, StatusCancelled, StatusFailed},
+	StatusRunning:   {StatusQueued, StatusJudged, StatusCancelled, StatusFailed},
+	StatusJudged:    {},
+	StatusCancelled: {},
+	StatusFailed:    {},
+}
+
+// PendingStatuses are the statuses of submissions that have not finished yet
+var PendingStatuses = []string{StatusQueued, StatusCompiling, StatusRunning}
+
+// IsValidStatus reports whether status is a known lifecycle status
+func IsValidStatus(status string) bool {
+	_, ok := submissionTransitions[status]
+	return ok
+}
+
+// IsTerminalStatus reports whether a submission in status can no longer change
+func IsTerminalStatus(status string) bool {
+	return IsValidStatus(status) && len(submissionTransitions[status]) == 0
+}
+
+// CanTransition reports whether a submission may move from one status to another
+func CanTransition(from, to string) bool {
+	for _, next := range submissionTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusesBefore returns the statuses a submission may move to status from.
+// It is used to guard status updates so a late or duplicate update never
+// overwrites a newer status.
+func StatusesBefore(status string) []string {
+	var from []string
+	for candidate := range submissionTransitions {
+		if CanTransition(candidate, status) {
+			from = append(from, candidate)
+		}
+	}
+	return from
+}
+
+// StatusTimestampColumn returns the submissions column that records when a
+// submission entered status
+func StatusTimestampColumn(status string) string {
+	switch status {
+	case StatusQueued:
+		return "queued_at"
+	case StatusCompiling:
+		return "compiling_at"
+	case StatusRunning:
+		return "running_at"
+	default:
+		return "finished_at"
+	}
+}
+
+// TransitionSubmission moves a submission to status and applies updates in the
+// same statement, recording when the status was entered. It reports false and
+// changes nothing if the submission's current status may not move to status.
+func TransitionSubmission(db *gorm.DB, submissionID uuid.UUID, status string, updates map[string]interface{}) (bool, error) {
+	values := map[string]interface{}{
+		"status":                      status,
+		StatusTimestampColumn(status): time.Now(),
+	}
+	for column, value := range updates {
+		values[column] = value
+	}
+
+	result := db.Model(&Submission{}).
+		Where("id = ? AND status IN ?", submissionID, StatusesBefore(status)).
+		Updates(values)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// MigrateSubmissionStatus adds the status column to submissions made before
+// lifecycle statuses existed. Judged submissions get the terminal status that
+// matches their verdict; those still pending stay queued for the reaper to
+// requeue. It must run before AutoMigrate adds the column with its default.
+func MigrateSubmissionStatus(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&Submission{}) || db.Migrator().HasColumn(&Submission{}, "status") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Migrator().AddColumn(&Submission{}, "Status"); err != nil {
+			return err
+		}
+
+		return tx.Exec(`
+			UPDATE submissions
+			SET status = CASE WHEN result = 'SE' THEN ? ELSE ? END
+			WHERE result <> 'pending'`, StatusFailed, StatusJudged).Error
+	})
+}
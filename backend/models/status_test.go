@@ -0,0 +1,152 @@
+package model
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{StatusQueued, StatusCompiling, true},
+		{StatusQueued, StatusJudged, true},
+		{StatusQueued, StatusQueued, false},
+		{StatusCompiling, StatusRunning, true},
+		{StatusCompiling, StatusQueued, true},
+		{StatusRunning, StatusCompiling, false},
+		{StatusRunning, StatusQueued, true},
+		{StatusRunning, StatusCancelled, true},
+		{StatusJudged, StatusQueued, false},
+		{StatusJudged, StatusFailed, false},
+		{StatusCancelled, StatusJudged, false},
+		{StatusFailed, StatusQueued, false},
+		{"unknown", StatusJudged, false},
+		{StatusQueued, "unknown", false},
+	}
+
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%q, %q) = %t, want %t", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestIsTerminalStatus(t *testing.T) {
+	tests := []struct {
+		status string
+		want   bool
+	}{
+		{StatusQueued, false},
+		{StatusCompiling, false},
+		{StatusRunning, false},
+		{StatusJudged, true},
+		{StatusCancelled, true},
+		{StatusFailed, true},
+		{"unknown", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsTerminalStatus(tt.status); got != tt.want {
+			t.Errorf("IsTerminalStatus(%q) = %t, want %t", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestStatusesBefore(t *testing.T) {
+	tests := []struct {
+		status string
+		want   []string
+	}{
+		{StatusQueued, []string{StatusCompiling, StatusRunning}},
+		{StatusCompiling, []string{StatusQueued}},
+		{StatusRunning, []string{StatusCompiling, StatusQueued}},
+		{StatusJudged, []string{StatusCompiling, StatusQueued, StatusRunning}},
+		{StatusFailed, []string{StatusCompiling, StatusQueued, StatusRunning}},
+	}
+
+	for _, tt := range tests {
+		got := StatusesBefore(tt.status)
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("StatusesBefore(%q) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
+
+// dryRunDB returns a database that builds statements without running them
+func dryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestTransitionSubmission(t *testing.T) {
+	tests := []struct {
+		status    string
+		column    string
+		guardedBy []string
+	}{
+		{StatusCompiling, "compiling_at", []string{StatusQueued}},
+		{StatusRunning, "running_at", []string{StatusCompiling, StatusQueued}},
+		{StatusQueued, "queued_at", []string{StatusCompiling, StatusRunning}},
+		{StatusJudged, "finished_at", []string{StatusCompiling, StatusQueued, StatusRunning}},
+		{StatusCancelled, "finished_at", []string{StatusCompiling, StatusQueued, StatusRunning}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			db := dryRunDB(t)
+			var statement *gorm.Statement
+			if err := db.Callback().Update().After("gorm:update").Register("test:capture", func(tx *gorm.DB) {
+				statement = tx.Statement
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			// A dry run affects no rows, so the transition reports it was not applied
+			applied, err := TransitionSubmission(db, uuid.New(), tt.status, map[string]interface{}{"result": "AC"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if applied {
+				t.Error("TransitionSubmission reported a dry run as applied")
+			}
+			if statement == nil {
+				t.Fatal("no update statement was built")
+			}
+
+			sql := statement.SQL.String()
+			for _, want := range []string{`"status"=`, `"` + tt.column + `"=`, `"result"=`, `status IN`} {
+				if !strings.Contains(sql, want) {
+					t.Errorf("statement %q does not contain %q", sql, want)
+				}
+			}
+
+			// The statuses the update is guarded by follow the submission ID
+			var guardedBy []string
+			afterID := false
+			for _, v := range statement.Vars {
+				if _, ok := v.(uuid.UUID); ok {
+					afterID = true
+				} else if status, ok := v.(string); ok && afterID {
+					guardedBy = append(guardedBy, status)
+				}
+			}
+			slices.Sort(guardedBy)
+			if !slices.Equal(guardedBy, tt.guardedBy) {
+				t.Errorf("update is guarded by statuses %v, want %v", guardedBy, tt.guardedBy)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package model
+
+import "testing"
+
+func TestForContestant(t *testing.T) {
+	tests := []struct {
+		name       string
+		hidden     bool
+		wantOutput string
+	}{
+		{"samples only", false, "42\n"},
+		{"ran on hidden tests", true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			submission := Submission{
+				HasHiddenTests: tt.hidden,
+				StdOutput:      "42\n",
+				StdError:       "debug: 42\n",
+				CompileOutput:  "warning: unused variable",
+				Result:         "WA",
+			}
+
+			got := submission.ForContestant()
+			if got.StdOutput != tt.wantOutput || (got.StdError != "") != !tt.hidden {
+				t.Errorf("ForContestant output = (%q, %q), want output %q", got.StdOutput, got.StdError, tt.wantOutput)
+			}
+			// The verdict and compiler messages never depend on the tests
+			if got.Result != submission.Result || got.CompileOutput != submission.CompileOutput {
+				t.Errorf("ForContestant changed the verdict or compile output: %+v", got)
+			}
+			if submission.StdOutput != "42\n" {
+				t.Error("ForContestant changed the original submission")
+			}
+		})
+	}
+}
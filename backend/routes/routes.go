@@ -2,7 +2,6 @@ package routes
 
 import (
 	handler "OJ-backend/controllers"
-	"OJ-backend/services/sse"
 
 	"github.com/labstack/echo/v4"
 )
@@ -12,6 +11,7 @@ func RegisterRoutes(e *echo.Echo) {
 	e.POST("/login", handler.Login)
 	e.POST("/admin/login", handler.AdminLogin)
 	e.GET("/contests", handler.GetAllContests)
+	e.GET("/health", handler.HealthCheck)
 
 	// Callback route for workers (HMAC authenticated)
 	e.POST("/callback/submission", handler.HandleSubmissionCallback)
@@ -21,24 +21,55 @@ func RegisterRoutes(e *echo.Echo) {
 	api.Use(handler.JWTMiddleware())
 	api.GET("/profile", handler.GetProfile)
 	api.PUT("/profile", handler.UpdateProfile)
+	api.GET("/contest/:id", handler.GetContestDetail)
+	api.POST("/contest/:id/register", handler.RegisterForContest)
+	api.DELETE("/contest/:id/register", handler.LeaveContest)
 	api.GET("/problems/:id", handler.GetAllProblemsByContestID)
+	api.GET("/archive", handler.GetArchiveProblems)
+	api.GET("/tags", handler.GetTags)
 	api.GET("/problem/:id", handler.GetProblemByID)
-	api.GET("/testcases/:id", handler.GetAllTestCasesByProblemID)
-	api.POST("/submit/:user_id/:problem_id", handler.HandleSubmission)
+	api.GET("/problem/:id/fastest", handler.GetFastestSubmissionsByProblemID)
+	api.GET("/testcases/:id", handler.GetSampleTestCasesByProblemID)
+	api.POST("/submit/:problem_id", handler.HandleSubmission)
+	api.GET("/submissions", handler.GetMySubmissions)
+	api.GET("/submission/:id", handler.GetSubmissionByID)
 	api.GET("/leaderboard/:contest_id", handler.GetLeaderboardByContestID)
+	api.GET("/leaderboard/:contest_id/events", handler.SubscribeLeaderboardEvents)
+	api.GET("/leaderboard/:contest_id/at", handler.GetLeaderboardAt)
+	api.GET("/leaderboard/:contest_id/history", handler.GetStandingsHistory)
 	// SSE endpoint for real-time submission updates
-	e.GET("/submission/:user_id/:submission_id/events", sse.HandleSSEConnection)
+	api.GET("/submission/:id/events", handler.SubscribeSubmissionEvents)
 
 	// Admin routes
 	admin := e.Group("/admin")
 	admin.Use(handler.AdminJWTMiddleware())
 	//contest routes
+	admin.GET("/contests", handler.AdminGetAllContests)
 	admin.POST("/create-contest", handler.CreateContest)
 	admin.PUT("/contest/:id", handler.UpdateContest)
 	admin.DELETE("/contest/:id", handler.DeleteContest)
+	admin.POST("/contest/:id/unfreeze", handler.UnfreezeContest)
+	admin.POST("/contest/:id/standings/rebuild", handler.RebuildStandings)
+	admin.GET("/contest/:id/resolver", handler.GetResolverData)
+	admin.GET("/leaderboard/:contest_id", handler.AdminGetLeaderboardByContestID)
+	admin.GET("/leaderboard/:contest_id/events", handler.AdminSubscribeLeaderboardEvents)
+	admin.GET("/leaderboard/:contest_id/at", handler.AdminGetLeaderboardAt)
+	admin.GET("/leaderboard/:contest_id/history", handler.AdminGetStandingsHistory)
+	//participant routes
+	admin.GET("/contest/:id/participants", handler.GetContestParticipants)
+	admin.POST("/contest/:id/participants", handler.AddContestParticipant)
+	admin.PUT("/contest/:id/participants/:user_id", handler.UpdateContestParticipant)
+	admin.DELETE("/contest/:id/participants/:user_id", handler.RemoveContestParticipant)
+	//contest problem routes
+	admin.POST("/contest/:id/problems", handler.AddContestProblem)
+	admin.PUT("/contest/:id/problems/order", handler.ReorderContestProblems)
+	admin.PUT("/contest/:id/problems/:problem_id", handler.UpdateContestProblem)
+	admin.DELETE("/contest/:id/problems/:problem_id", handler.RemoveContestProblem)
 	//problem routes
+	admin.GET("/problems", handler.AdminGetAllProblems)
+	admin.POST("/create-problem", handler.CreateProblem)
 	admin.POST("/create-problem/:id", handler.CreateProblem)
-	admin.GET("/problems/:id", handler.GetAllProblemsByContestID)
+	admin.GET("/problems/:id", handler.AdminGetAllProblemsByContestID)
 	admin.PUT("/problem/:id", handler.UpdateProblem)
 	admin.DELETE("/problem/:id", handler.DeleteProblem)
 	//test case routes
@@ -46,4 +77,8 @@ func RegisterRoutes(e *echo.Echo) {
 	admin.GET("/testcases/:id", handler.GetAllTestCasesByProblemID)
 	admin.PUT("/testcase/:id", handler.UpdateTestCase)
 	admin.DELETE("/testcase/:id", handler.DeleteTestCase)
+	//submission routes
+	admin.GET("/submissions", handler.AdminGetSubmissions)
+	admin.GET("/submission/:id", handler.AdminGetSubmissionByID)
+	admin.POST("/submission/:id/cancel", handler.CancelSubmission)
 }
@@ -8,12 +8,17 @@ import (
 	"OJ-backend/routes"
 	"OJ-backend/models"
 	rabbitmq "OJ-backend/services/rabbitmq"
+	"OJ-backend/services/outbox"
+	"OJ-backend/services/reaper"
+	"OJ-backend/services/replay"
 )
 
 func main() {
 	// Load environment variables
 	config.LoadEnv()
 
+	// Connect to RabbitMQ in the background, reconnecting whenever the broker goes away
+	rabbitmq.Init()
 	//Close rabbitmq connection when the application exits
 	defer rabbitmq.CloseRabbitMQ()
 
@@ -33,7 +38,37 @@ func main() {
 	} else {
 		e.Logger.Info("Successfully connected to the database", db.Name())
 	}
-	db.AutoMigrate(model.User{}, model.Contest{}, model.Problem{}, model.Submission{},model.TestCase{}, model.Language{})
+	// Use ContestUser for the contest_users join table so it can carry registration details
+	db.SetupJoinTable(&model.Contest{}, "Users", &model.ContestUser{})
+	db.SetupJoinTable(&model.User{}, "Contests", &model.ContestUser{})
+	// Use ContestProblem for the contest_problems join table so it can carry each problem's label and points
+	db.SetupJoinTable(&model.Contest{}, "Problems", &model.ContestProblem{})
+	db.SetupJoinTable(&model.Problem{}, "Contests", &model.ContestProblem{})
+	// Give submissions judged before lifecycle statuses existed a finished status
+	if err := model.MigrateSubmissionStatus(db); err != nil {
+		e.Logger.Fatal("Failed to migrate submission statuses:", err)
+	}
+	db.AutoMigrate(model.User{}, model.Contest{}, model.Problem{}, model.Submission{},model.TestCase{}, model.Language{}, model.OutboxMessage{}, model.CallbackDelivery{}, model.ContestUser{}, model.Standing{}, model.ContestProblem{}, model.Tag{})
+
+	// Link problems created before the archive to their contest
+	if err := model.MigrateContestProblems(db); err != nil {
+		e.Logger.Fatal("Failed to migrate contest problems:", err)
+	}
+	if err := model.MigrateProblemSearch(db); err != nil {
+		e.Logger.Fatal("Failed to create problem search index:", err)
+	}
+	if err := model.MigrateOutbox(db); err != nil {
+		e.Logger.Fatal("Failed to create outbox index:", err)
+	}
+
+	// Publish submissions written to the outbox
+	outbox.Start()
+
+	// Forget callback deliveries once they are too old to be replayed
+	replay.Start()
+
+	// Recover submissions stuck in "pending"
+	reaper.Start()
 
 	// Register routes
 	routes.RegisterRoutes(e)
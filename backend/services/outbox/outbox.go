@@ -0,0 +1,196 @@
+package outbox
+
+import (
+	"OJ-backend/config"
+	model "OJ-backend/models"
+	"OJ-backend/services/rabbitmq"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Defaults used when the OUTBOX_* environment variables are unset
+const (
+	defaultPollInterval = 5 * time.Second
+	defaultBatchSize    = 50
+	defaultClaimTimeout = 5 * time.Minute
+	defaultRetention    = 7 * 24 * time.Hour
+)
+
+// pruneInterval is how often sent messages past their retention are deleted
+const pruneInterval = time.Hour
+
+// wake lets writers trigger a relay run without waiting for the next poll
+var wake = make(chan struct{}, 1)
+
+// Enqueue writes a submission payload to the outbox using the given transaction.
+// Call Notify once the transaction has committed.
+func Enqueue(tx *gorm.DB, payload model.RabbitMQPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	message := model.OutboxMessage{
+		ID:           uuid.New(),
+		SubmissionID: payload.SubmissionID,
+		Payload:      string(body),
+	}
+
+	return tx.Create(&message).Error
+}
+
+// Notify wakes the relay so newly committed messages are published right away
+func Notify() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start launches the relay goroutine that publishes unsent outbox messages.
+// A message claimed by a relay that has not published it within
+// OUTBOX_CLAIM_TIMEOUT, for example because its backend instance died, is
+// claimed again, so the timeout must be longer than publishing a whole batch.
+// Sent messages are deleted once they are older than OUTBOX_RETENTION, which
+// must stay well above PENDING_REAPER_THRESHOLD since the reaper counts the
+// messages sent after a submission's to tell whether it is still queued.
+func Start() {
+	pollInterval := config.GetEnvDuration("OUTBOX_POLL_INTERVAL", defaultPollInterval)
+	batchSize := config.GetEnvInt("OUTBOX_BATCH_SIZE", defaultBatchSize)
+	claimTimeout := config.GetEnvDuration("OUTBOX_CLAIM_TIMEOUT", defaultClaimTimeout)
+	retention := config.GetEnvDuration("OUTBOX_RETENTION", defaultRetention)
+
+	log.Printf("Starting outbox relay (poll interval %s, batch size %d, claim timeout %s, retention %s)", pollInterval, batchSize, claimTimeout, retention)
+
+	go func() {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-wake:
+			}
+			relayPending(batchSize, claimTimeout)
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(pruneInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := prune(config.DB, time.Now().Add(-retention)).Error; err != nil {
+				log.Printf("Failed to prune sent outbox messages: %v", err)
+			}
+		}
+	}()
+}
+
+// relayPending publishes one batch of unsent messages in creation order. The
+// batch is claimed in a short transaction first, so no database connection or
+// row lock is held while waiting for the broker.
+func relayPending(batchSize int, claimTimeout time.Duration) {
+	db := config.DB
+
+	messages, err := claim(db, batchSize, claimTimeout)
+	if err != nil {
+		log.Printf("Outbox relay failed to claim messages: %v", err)
+		return
+	}
+
+	for i, message := range messages {
+		if err := rabbitmq.PublishSubmission([]byte(message.Payload)); err != nil {
+			log.Printf("Outbox failed to publish message %s for submission %s: %v", message.ID, message.SubmissionID, err)
+			if err := db.Model(&message).Updates(map[string]interface{}{
+				"attempts":   message.Attempts + 1,
+				"last_error": err.Error(),
+			}).Error; err != nil {
+				log.Printf("Outbox failed to record error for message %s: %v", message.ID, err)
+			}
+			// The broker is most likely unavailable, so hand the rest back for the next run
+			release(db, messages[i:])
+			return
+		}
+
+		if err := markSent(db, message); err != nil {
+			log.Printf("Outbox failed to mark message %s as sent: %v", message.ID, err)
+		}
+	}
+}
+
+// claim picks up to batchSize unsent messages that no relay is publishing and
+// marks them as claimed. Rows are locked with SKIP LOCKED only while claiming,
+// so several backend instances can relay concurrently without claiming the
+// same message.
+func claim(db *gorm.DB, batchSize int, claimTimeout time.Duration) ([]model.OutboxMessage, error) {
+	var messages []model.OutboxMessage
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := claimable(tx, batchSize, now.Add(-claimTimeout)).Find(&messages).Error; err != nil {
+			return err
+		}
+		if len(messages) == 0 {
+			return nil
+		}
+
+		ids := make([]uuid.UUID, 0, len(messages))
+		for _, message := range messages {
+			ids = append(ids, message.ID)
+		}
+		return tx.Model(&model.OutboxMessage{}).Where("id IN ?", ids).Update("claimed_at", now).Error
+	})
+
+	return messages, err
+}
+
+// claimable selects up to batchSize unsent messages, oldest first, that are not
+// claimed or were claimed before claimedBefore, locking them for the caller's
+// transaction. It matches the partial index created by MigrateOutbox.
+func claimable(tx *gorm.DB, batchSize int, claimedBefore time.Time) *gorm.DB {
+	return tx.
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("sent_at IS NULL AND (claimed_at IS NULL OR claimed_at < ?)", claimedBefore).
+		Order("created_at ASC").
+		Limit(batchSize)
+}
+
+// prune deletes the messages sent before cutoff
+func prune(db *gorm.DB, cutoff time.Time) *gorm.DB {
+	return db.Where("sent_at < ?", cutoff).Delete(&model.OutboxMessage{})
+}
+
+// release hands claimed messages back so the next run publishes them
+func release(db *gorm.DB, messages []model.OutboxMessage) {
+	ids := make([]uuid.UUID, 0, len(messages))
+	for _, message := range messages {
+		ids = append(ids, message.ID)
+	}
+
+	if err := db.Model(&model.OutboxMessage{}).Where("id IN ?", ids).Update("claimed_at", nil).Error; err != nil {
+		log.Printf("Outbox failed to release %d messages: %v", len(ids), err)
+	}
+}
+
+// markSent records that the broker confirmed a message
+func markSent(db *gorm.DB, message model.OutboxMessage) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&message).Updates(map[string]interface{}{
+			"attempts": message.Attempts + 1,
+			"sent_at":  now,
+		}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&model.Submission{}).
+			Where("id = ?", message.SubmissionID).
+			Update("last_published_at", now).Error
+	})
+}
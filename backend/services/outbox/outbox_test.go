@@ -0,0 +1,53 @@
+package outbox
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// dryRunDB returns a database that builds statements without running them
+func dryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestQueries(t *testing.T) {
+	at := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		query func(*gorm.DB) *gorm.DB
+		want  string
+	}{
+		{
+			// Must keep matching idx_outbox_messages_unsent
+			name: "claim unsent messages oldest first",
+			query: func(db *gorm.DB) *gorm.DB {
+				return claimable(db, 50, at).Find(&[]struct{ ID string }{})
+			},
+			want: "WHERE sent_at IS NULL AND (claimed_at IS NULL OR claimed_at < $1) ORDER BY created_at ASC LIMIT $2 FOR UPDATE SKIP LOCKED",
+		},
+		{
+			name:  "prune only sent messages",
+			query: func(db *gorm.DB) *gorm.DB { return prune(db, at) },
+			want:  `DELETE FROM "outbox_messages" WHERE sent_at < $1`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statement := tt.query(dryRunDB(t)).Statement
+			if sql := statement.SQL.String(); !strings.Contains(sql, tt.want) {
+				t.Errorf("query = %s, want it to contain %s", sql, tt.want)
+			}
+		})
+	}
+}
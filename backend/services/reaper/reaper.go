@@ -0,0 +1,214 @@
+package reaper
+
+import (
+	"OJ-backend/config"
+	model "OJ-backend/models"
+	"OJ-backend/services/outbox"
+	"OJ-backend/services/rabbitmq"
+	"OJ-backend/services/scoreboard"
+	"OJ-backend/services/sse"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// errAlreadyHandled reports that a submission finished or was re-published elsewhere while being re-published
+var errAlreadyHandled = errors.New("submission is no longer pending")
+
+// Defaults used when the PENDING_REAPER_* environment variables are unset
+const (
+	defaultInterval    = time.Minute
+	defaultThreshold   = 10 * time.Minute
+	defaultMaxAttempts = 3
+)
+
+// Start launches the background job that recovers submissions that never finish judging.
+//
+// A submission is considered stale once it has been queued, compiling or running for longer than
+// PENDING_REAPER_THRESHOLD since it was last sent to the queue or last changed
+// status, and has no unsent outbox message. A stale submission whose message
+// is still waiting in the queue is left alone, as is everything while no worker
+// consumes the queue. The threshold must be longer than the slowest judge run,
+// since a submission being judged is not visible in the queue.
+func Start() {
+	interval := config.GetEnvDuration("PENDING_REAPER_INTERVAL", defaultInterval)
+	threshold := config.GetEnvDuration("PENDING_REAPER_THRESHOLD", defaultThreshold)
+	maxAttempts := config.GetEnvInt("PENDING_REAPER_MAX_ATTEMPTS", defaultMaxAttempts)
+
+	log.Printf("Starting pending submission reaper (interval %s, threshold %s, max attempts %d)", interval, threshold, maxAttempts)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			reapStaleSubmissions(threshold, maxAttempts)
+		}
+	}()
+}
+
+// reapAction is what the reaper does with a stale submission
+type reapAction int
+
+const (
+	// reapWait leaves the submission alone, since it may still be judged
+	reapWait reapAction = iota
+	reapRepublish
+	reapFail
+)
+
+// decideReap chooses what to do with a stale submission. messagesAfter is the
+// number of queue messages published after the submission's last one. The
+// queue is first in, first out, so while fewer messages than are waiting were
+// published after it, the submission's own message is still in the queue.
+// Nothing is done while no worker consumes the queue, since republishing
+// would not help and failing would throw away the verdict once one returns.
+func decideReap(submission model.Submission, messagesAfter int64, queue rabbitmq.QueueStats, maxAttempts int) reapAction {
+	if queue.Consumers == 0 {
+		return reapWait
+	}
+	if submission.Status == model.StatusQueued && submission.LastPublishedAt != nil && messagesAfter < int64(queue.Messages) {
+		return reapWait
+	}
+	if submission.PublishAttempts < maxAttempts {
+		return reapRepublish
+	}
+	return reapFail
+}
+
+// reapStaleSubmissions re-publishes stale pending submissions that are no
+// longer waiting in the queue, or marks them as system errors once they have
+// used up their publish attempts
+func reapStaleSubmissions(threshold time.Duration, maxAttempts int) {
+	db := config.DB
+	var submissions []model.Submission
+
+	// GREATEST ignores NULLs, so this is the submission's latest sign of progress
+	cutoff := time.Now().Add(-threshold)
+	if err := db.
+		Where("status IN ? AND result = ?", model.PendingStatuses, "pending").
+		Where("GREATEST(submitted_at, last_published_at, queued_at, compiling_at, running_at) < ?", cutoff).
+		Where("NOT EXISTS (SELECT 1 FROM outbox_messages WHERE outbox_messages.submission_id = submissions.id AND outbox_messages.sent_at IS NULL)").
+		Find(&submissions).Error; err != nil {
+		log.Printf("Reaper failed to query pending submissions: %v", err)
+		return
+	}
+
+	if len(submissions) == 0 {
+		return
+	}
+
+	queue, err := rabbitmq.Stats()
+	if err != nil {
+		log.Printf("Reaper failed to inspect submissions queue: %v", err)
+		return
+	}
+
+	for _, submission := range submissions {
+		var messagesAfter int64
+		if submission.Status == model.StatusQueued && submission.LastPublishedAt != nil {
+			if err := db.Model(&model.OutboxMessage{}).
+				Where("sent_at > ?", *submission.LastPublishedAt).
+				Count(&messagesAfter).Error; err != nil {
+				log.Printf("Reaper failed to find the queue position of submission %s: %v", submission.ID, err)
+				continue
+			}
+		}
+
+		switch decideReap(submission, messagesAfter, queue, maxAttempts) {
+		case reapRepublish:
+			republishSubmission(submission)
+		case reapFail:
+			failSubmission(submission)
+		}
+	}
+}
+
+// republishSubmission sends a stale submission to the queue again
+func republishSubmission(submission model.Submission) {
+	db := config.DB
+
+	var language model.Language
+	if err := db.First(&language, "name = ?", submission.Language).Error; err != nil {
+		log.Printf("Reaper failed to load language %s for submission %s: %v", submission.Language, submission.ID, err)
+		failSubmission(submission)
+		return
+	}
+
+	// Claim the submission and write its queue message together so that a
+	// callback which arrived in the meantime is not overwritten. Requeueing
+	// resets the submission to queued whatever stage its lost worker reached.
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.Submission{}).
+			Where("id = ? AND status IN ? AND publish_attempts = ?", submission.ID, model.PendingStatuses, submission.PublishAttempts).
+			Updates(map[string]interface{}{
+				"status":           model.StatusQueued,
+				"queued_at":        time.Now(),
+				"publish_attempts": submission.PublishAttempts + 1,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errAlreadyHandled
+		}
+		return outbox.Enqueue(tx, rabbitmq.NewSubmissionPayload(submission, language))
+	})
+	if errors.Is(err, errAlreadyHandled) {
+		return
+	}
+	if err != nil {
+		log.Printf("Reaper failed to re-publish submission %s: %v", submission.ID, err)
+		return
+	}
+
+	outbox.Notify()
+
+	log.Printf("Reaper re-published submission %s (attempt %d)", submission.ID, submission.PublishAttempts+1)
+
+	sse.GlobalSSEManager.PushToUser(submission.UserID.String(), submission.ID.String(), sse.SubmissionUpdate{
+		SubmissionID: submission.ID.String(),
+		Result:       "pending",
+		State:        model.StatusQueued,
+		Status:       "requeued",
+		Message:      "Submission was requeued for judging",
+	})
+}
+
+// failSubmission marks a stale submission as a system error
+func failSubmission(submission model.Submission) {
+	db := config.DB
+
+	applied := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		applied, err = model.TransitionSubmission(tx, submission.ID, model.StatusFailed, map[string]interface{}{"result": "SE"})
+		if err != nil || !applied || submission.IsPractice {
+			return err
+		}
+		return scoreboard.Refresh(tx, *submission.ContestID, submission.UserID, submission.ProblemID)
+	})
+	if err != nil {
+		log.Printf("Reaper failed to mark submission %s as SE: %v", submission.ID, err)
+		return
+	}
+	if !applied {
+		return
+	}
+
+	log.Printf("Reaper marked submission %s as SE after %d attempts", submission.ID, submission.PublishAttempts)
+
+	sse.GlobalSSEManager.BroadcastToUser(submission.UserID.String(), submission.ID.String(), sse.SubmissionUpdate{
+		SubmissionID: submission.ID.String(),
+		Result:       "SE",
+		Message:      "Submission could not be judged",
+		State:        model.StatusFailed,
+		Status:       "completed",
+	})
+
+	if !submission.IsPractice {
+		scoreboard.Publish(db, *submission.ContestID)
+	}
+}
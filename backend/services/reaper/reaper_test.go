@@ -0,0 +1,43 @@
+package reaper
+
+import (
+	model "OJ-backend/models"
+	"OJ-backend/services/rabbitmq"
+	"testing"
+	"time"
+)
+
+func TestDecideReap(t *testing.T) {
+	published := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	submission := func(status string, attempts int, lastPublished *time.Time) model.Submission {
+		return model.Submission{Status: status, PublishAttempts: attempts, LastPublishedAt: lastPublished}
+	}
+	busy := rabbitmq.QueueStats{Messages: 40, Consumers: 2}
+
+	tests := []struct {
+		name          string
+		submission    model.Submission
+		messagesAfter int64
+		queue         rabbitmq.QueueStats
+		want          reapAction
+	}{
+		{"still waiting in the queue", submission(model.StatusQueued, 1, &published), 25, busy, reapWait},
+		{"still in the queue after its last attempt", submission(model.StatusQueued, 3, &published), 39, busy, reapWait},
+		{"consumed from the queue but never judged", submission(model.StatusQueued, 1, &published), 40, busy, reapRepublish},
+		{"lost from an empty queue", submission(model.StatusQueued, 1, &published), 0, rabbitmq.QueueStats{Consumers: 1}, reapRepublish},
+		{"never published", submission(model.StatusQueued, 0, nil), 0, busy, reapRepublish},
+		{"worker lost while compiling", submission(model.StatusCompiling, 1, &published), 0, busy, reapRepublish},
+		{"worker lost while running", submission(model.StatusRunning, 2, &published), 0, busy, reapRepublish},
+		{"out of attempts", submission(model.StatusRunning, 3, &published), 0, busy, reapFail},
+		{"no worker consumes the queue", submission(model.StatusQueued, 3, &published), 40, rabbitmq.QueueStats{Messages: 10}, reapWait},
+		{"no worker to finish a running submission", submission(model.StatusRunning, 3, &published), 0, rabbitmq.QueueStats{}, reapWait},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decideReap(tt.submission, tt.messagesAfter, tt.queue, 3); got != tt.want {
+				t.Errorf("decideReap = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package replay
+
+import (
+	"OJ-backend/config"
+	model "OJ-backend/models"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// defaultMaxSkew is used when CALLBACK_MAX_SKEW is unset
+const defaultMaxSkew = 5 * time.Minute
+
+var (
+	ErrMissingTimestamp = errors.New("missing or invalid timestamp")
+	ErrStaleTimestamp   = errors.New("timestamp outside the allowed window")
+)
+
+// MaxSkew is how far a callback timestamp may be from the server clock
+func MaxSkew() time.Duration {
+	return config.GetEnvDuration("CALLBACK_MAX_SKEW", defaultMaxSkew)
+}
+
+// CheckTimestamp validates a unix timestamp header against the freshness window
+func CheckTimestamp(header string) error {
+	seconds, err := strconv.ParseInt(header, 10, 64)
+	if err != nil {
+		return ErrMissingTimestamp
+	}
+
+	// Compare both ways rather than negating, which overflows for timestamps
+	// so far off that the difference is clamped
+	skew, maxSkew := time.Since(time.Unix(seconds, 0)), MaxSkew()
+	if skew > maxSkew || skew < -maxSkew {
+		return ErrStaleTimestamp
+	}
+
+	return nil
+}
+
+// Claim records a delivery ID using the given transaction. It reports false if
+// the delivery was already claimed, in which case the callback is a duplicate.
+func Claim(tx *gorm.DB, deliveryID string, submissionID uuid.UUID) (bool, error) {
+	delivery := model.CallbackDelivery{
+		ID:           deliveryID,
+		SubmissionID: submissionID,
+		ReceivedAt:   time.Now(),
+	}
+
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&delivery)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// Start launches a goroutine that forgets deliveries once their timestamps
+// could no longer pass CheckTimestamp
+func Start() {
+	go func() {
+		ticker := time.NewTicker(MaxSkew())
+		defer ticker.Stop()
+
+		for range ticker.C {
+			cutoff := time.Now().Add(-2 * MaxSkew())
+			if err := config.DB.Where("received_at < ?", cutoff).Delete(&model.CallbackDelivery{}).Error; err != nil {
+				log.Printf("Failed to prune callback deliveries: %v", err)
+			}
+		}
+	}()
+}
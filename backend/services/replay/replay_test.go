@@ -0,0 +1,44 @@
+package replay
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCheckTimestamp(t *testing.T) {
+	t.Setenv("CALLBACK_MAX_SKEW", "5m")
+	now := time.Now()
+	unix := func(at time.Time) string { return strconv.FormatInt(at.Unix(), 10) }
+
+	tests := []struct {
+		name   string
+		header string
+		want   error
+	}{
+		{"current", unix(now), nil},
+		{"slightly behind", unix(now.Add(-4 * time.Minute)), nil},
+		{"slightly ahead", unix(now.Add(4 * time.Minute)), nil},
+		{"too old", unix(now.Add(-6 * time.Minute)), ErrStaleTimestamp},
+		{"too far ahead", unix(now.Add(6 * time.Minute)), ErrStaleTimestamp},
+		{"missing", "", ErrMissingTimestamp},
+		{"not a number", "yesterday", ErrMissingTimestamp},
+		{"milliseconds", strconv.FormatInt(now.UnixMilli(), 10), ErrStaleTimestamp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckTimestamp(tt.header); err != tt.want {
+				t.Errorf("CheckTimestamp(%q) = %v, want %v", tt.header, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckTimestampWindow(t *testing.T) {
+	t.Setenv("CALLBACK_MAX_SKEW", "30s")
+
+	if err := CheckTimestamp(strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)); err != ErrStaleTimestamp {
+		t.Errorf("a minute old timestamp with a 30s window = %v, want %v", err, ErrStaleTimestamp)
+	}
+}
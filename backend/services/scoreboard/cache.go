@@ -0,0 +1,62 @@
+package scoreboard
+
+import (
+	"OJ-backend/config"
+	model "OJ-backend/models"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// defaultCacheTTL is used when SCOREBOARD_CACHE_TTL is unset. Changes made
+// through this backend invalidate the cache straight away; the TTL bounds how
+// late a time-based change such as the start of the freeze shows up.
+const defaultCacheTTL = 10 * time.Second
+
+// cacheKey identifies a cached view of a contest's scoreboard
+type cacheKey struct {
+	contestID uuid.UUID
+	frozen    bool
+}
+
+type cacheEntry struct {
+	board     model.Scoreboard
+	expiresAt time.Time
+}
+
+var cache = struct {
+	sync.RWMutex
+	entries map[cacheKey]cacheEntry
+}{entries: make(map[cacheKey]cacheEntry)}
+
+// cached returns a scoreboard built recently enough to be served again
+func cached(key cacheKey) (model.Scoreboard, bool) {
+	cache.RLock()
+	defer cache.RUnlock()
+
+	entry, ok := cache.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return model.Scoreboard{}, false
+	}
+	return entry.board, true
+}
+
+// store caches a scoreboard
+func store(key cacheKey, board model.Scoreboard) {
+	ttl := config.GetEnvDuration("SCOREBOARD_CACHE_TTL", defaultCacheTTL)
+
+	cache.Lock()
+	defer cache.Unlock()
+
+	cache.entries[key] = cacheEntry{board: board, expiresAt: time.Now().Add(ttl)}
+}
+
+// Invalidate drops the cached scoreboards of a contest
+func Invalidate(contestID uuid.UUID) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	delete(cache.entries, cacheKey{contestID: contestID, frozen: false})
+	delete(cache.entries, cacheKey{contestID: contestID, frozen: true})
+}
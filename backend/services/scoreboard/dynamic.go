@@ -0,0 +1,134 @@
+package scoreboard
+
+import (
+	model "OJ-backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// computeDynamic scores participants like IOI, except that each solved problem
+// is worth its points decayed by when it was first solved and by the rejected
+// attempts before that. Cells are counted as in ICPC, so their score can always
+// be worked out again from the contest's current settings.
+func computeDynamic(contest model.Contest, problems []model.ContestProblem, participants []model.ParticipantEntry, submissions []model.Submission) []model.LeaderboardEntry {
+	cells := icpcCells(contest, submissions)
+	for _, cell := range cells {
+		cell.Penalty = 0
+	}
+
+	return buildEntries(contest, model.ScoringDynamic, problems, participants, cells)
+}
+
+// dynamicScore returns what a dynamic cell is worth on a problem. Every counted
+// attempt before the first accepted one was rejected.
+func dynamicScore(contest model.Contest, problem model.ContestProblem, cell model.ProblemResult) int {
+	if !cell.Solved || cell.SolvedAt == nil {
+		return 0
+	}
+	return contest.DynamicScore(problem.Points, *cell.SolvedAt, cell.Attempts-1)
+}
+
+// awardDynamic returns what each accepted submission of one cell is worth,
+// from its submission time and the rejected attempts submitted before it.
+// Submissions must be ordered by submission time; those not judged yet are
+// not counted.
+func awardDynamic(contest model.Contest, problem model.ContestProblem, submissions []model.Submission) map[uuid.UUID]int {
+	awarded := make(map[uuid.UUID]int)
+	wrongAttempts := 0
+	for _, submission := range submissions {
+		if submission.Status != model.StatusJudged {
+			continue
+		}
+		if submission.Result == "AC" {
+			awarded[submission.ID] = contest.DynamicScore(problem.Points, submission.SubmittedAt, wrongAttempts)
+		} else if countsAsAttempt(submission.Result) {
+			wrongAttempts++
+		}
+	}
+	return awarded
+}
+
+// AwardedScore returns the score to store for a submission that has just been
+// judged. An accepted ranked submission in a dynamic contest is worth its
+// problem's points decayed by its submission time and the rejected attempts
+// judged before it; anything else keeps the judge's score. Rebuild stamps the
+// scores again once every earlier attempt is judged or the settings change.
+func AwardedScore(tx *gorm.DB, submission model.Submission, result string, judgeScore int) (int, error) {
+	if submission.IsPractice || submission.ContestID == nil || result != "AC" {
+		return judgeScore, nil
+	}
+
+	var contest model.Contest
+	if err := tx.First(&contest, "id = ?", *submission.ContestID).Error; err != nil {
+		return 0, err
+	}
+	if contest.ScoringMode != model.ScoringDynamic {
+		return judgeScore, nil
+	}
+
+	var problem model.ContestProblem
+	if err := tx.First(&problem, "contest_id = ? AND problem_id = ?", contest.ID, submission.ProblemID).Error; err != nil {
+		// The problem was taken out of the contest while this was being judged
+		if err == gorm.ErrRecordNotFound {
+			return judgeScore, nil
+		}
+		return 0, err
+	}
+
+	var earlier []model.Submission
+	if err := tx.
+		Where("contest_id = ? AND user_id = ? AND problem_id = ? AND is_practice = ? AND status = ? AND submitted_at < ?",
+			contest.ID, submission.UserID, submission.ProblemID, false, model.StatusJudged, submission.SubmittedAt).
+		Order("submitted_at ASC").
+		Find(&earlier).Error; err != nil {
+		return 0, err
+	}
+
+	submission.Status, submission.Result = model.StatusJudged, result
+	return awardDynamic(contest, problem, append(earlier, submission))[submission.ID], nil
+}
+
+// rescore stamps the ranked accepted submissions of a contest with what they
+// are worth under its current settings: dynamic points in dynamic contests and
+// the judge's full score otherwise. Submissions must be ordered by submission time.
+func rescore(tx *gorm.DB, contest model.Contest, problems []model.ContestProblem, submissions []model.Submission) error {
+	want := make(map[uuid.UUID]int)
+	if contest.ScoringMode == model.ScoringDynamic {
+		byID := make(map[uuid.UUID]model.ContestProblem, len(problems))
+		for _, problem := range problems {
+			byID[problem.ProblemID] = problem
+		}
+		cells := make(map[cellKey][]model.Submission)
+		for _, submission := range submissions {
+			key := cellKey{submission.UserID, submission.ProblemID}
+			cells[key] = append(cells[key], submission)
+		}
+		for key, cellSubmissions := range cells {
+			problem, ok := byID[key.problemID]
+			if !ok {
+				continue
+			}
+			for id, score := range awardDynamic(contest, problem, cellSubmissions) {
+				want[id] = score
+			}
+		}
+	}
+
+	for _, submission := range submissions {
+		if submission.Status != model.StatusJudged || submission.Result != "AC" {
+			continue
+		}
+		score, ok := want[submission.ID]
+		if !ok {
+			score = model.JudgeMaxScore
+		}
+		if score == submission.Score {
+			continue
+		}
+		if err := tx.Model(&model.Submission{}).Where("id = ?", submission.ID).Update("score", score).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package scoreboard
+
+import (
+	model "OJ-backend/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// dynamicContest returns a dynamic contest losing 4 per mille a minute down to
+// 30%, and 50 points for each rejected attempt
+func dynamicContest() model.Contest {
+	contest := testContest(model.ScoringDynamic)
+	contest.DecayPerMille = 4
+	contest.FloorPercent = 30
+	contest.WrongAttemptPenalty = 50
+	return contest
+}
+
+func TestComputeDynamic(t *testing.T) {
+	problems := testProblems(1, 1000)
+	a := problems[0]
+	participants, users := testUsers("alice")
+	alice := users["alice"]
+	freezeAt := contestStart.Add(60 * time.Minute)
+
+	tests := []struct {
+		name        string
+		submissions []model.Submission
+		frozenAt    *time.Time
+		score       int
+		pending     int
+	}{
+		{
+			name:        "decays with the solve time",
+			submissions: []model.Submission{judged(alice, a, 10, "AC", 100)},
+			score:       960,
+		},
+		{
+			name: "rejected attempts before the solve cost points, compile errors do not",
+			submissions: []model.Submission{
+				judged(alice, a, 2, "WA", 0),
+				judged(alice, a, 4, "CE", 0),
+				judged(alice, a, 10, "AC", 100),
+			},
+			score: 910,
+		},
+		{
+			name: "attempts after the solve change nothing",
+			submissions: []model.Submission{
+				judged(alice, a, 10, "AC", 100),
+				judged(alice, a, 11, "WA", 0),
+				judged(alice, a, 12, "AC", 100),
+			},
+			score: 960,
+		},
+		{
+			// The penalty depends on submission order, not on which verdict arrived first
+			name: "an earlier attempt judged after the solve still costs points",
+			submissions: []model.Submission{
+				judged(alice, a, 2, "WA", 0),
+				judged(alice, a, 10, "AC", 100),
+			},
+			score: 910,
+		},
+		{
+			name: "an earlier attempt still being judged does not cost points yet",
+			submissions: []model.Submission{
+				queued(alice, a, 2),
+				judged(alice, a, 10, "AC", 100),
+			},
+			score: 960,
+		},
+		{
+			name: "partial judge scores are worth nothing",
+			submissions: []model.Submission{
+				judged(alice, a, 10, "WA", 90),
+			},
+			score: 0,
+		},
+		{
+			name:        "never below the floor",
+			submissions: []model.Submission{judged(alice, a, 280, "AC", 100)},
+			score:       300,
+		},
+		{
+			name:        "solves during the freeze are hidden",
+			submissions: []model.Submission{judged(alice, a, 70, "AC", 100)},
+			frozenAt:    &freezeAt,
+			score:       0,
+			pending:     1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			board := Compute(dynamicContest(), problems, participants, tt.submissions, tt.frozenAt)
+
+			entry := entryOf(t, board, alice)
+			if got := entry.Problems[0]; got.Score != tt.score || got.Pending != tt.pending || got.Penalty != 0 {
+				t.Errorf("cell scored %d with %d pending and %d penalty, want %d with %d pending and none", got.Score, got.Pending, got.Penalty, tt.score, tt.pending)
+			}
+			if entry.TotalScore != tt.score {
+				t.Errorf("total %d, want %d", entry.TotalScore, tt.score)
+			}
+		})
+	}
+}
+
+func TestDynamicCellsFollowContestSettings(t *testing.T) {
+	problems := testProblems(1, 1000)
+	a := problems[0]
+	_, users := testUsers("alice")
+	alice := users["alice"]
+	submissions := []model.Submission{
+		judged(alice, a, 5, "WA", 0),
+		judged(alice, a, 20, "AC", 100),
+	}
+
+	// Standings store the cell once; its score is worked out when the scoreboard is built
+	cell := cellResult(dynamicContest(), alice, a.ProblemID, submissions, nil)
+
+	tests := []struct {
+		name   string
+		change func(*model.Contest)
+		want   int
+	}{
+		{"current settings", func(*model.Contest) {}, 1000 - 80 - 50},
+		{"slower decay", func(c *model.Contest) { c.DecayPerMille = 1 }, 1000 - 20 - 50},
+		{"no wrong attempt penalty", func(c *model.Contest) { c.WrongAttemptPenalty = 0 }, 1000 - 80},
+		{"higher floor", func(c *model.Contest) { c.FloorPercent = 95 }, 950},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contest := dynamicContest()
+			tt.change(&contest)
+
+			if got := cellScore(contest, model.ScoringDynamic, a, cell); got != tt.want {
+				t.Errorf("cellScore = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAwardDynamic(t *testing.T) {
+	problems := testProblems(1, 1000)
+	a := problems[0]
+	_, users := testUsers("alice")
+	alice := users["alice"]
+
+	wa := judged(alice, a, 2, "WA", 0)
+	ce := judged(alice, a, 4, "CE", 0)
+	first := judged(alice, a, 10, "AC", 100)
+	pending := queued(alice, a, 11)
+	second := judged(alice, a, 20, "AC", 100)
+
+	awarded := awardDynamic(dynamicContest(), a, []model.Submission{wa, ce, first, pending, second})
+
+	want := map[uuid.UUID]int{
+		// 4% decay and one rejected attempt; the compile error is free
+		first.ID: 1000 - 40 - 50,
+		// Later solves are scored on their own, after the same attempts
+		second.ID: 1000 - 80 - 50,
+	}
+	if len(awarded) != len(want) {
+		t.Fatalf("awarded %d submissions, want %d: %v", len(awarded), len(want), awarded)
+	}
+	for id, score := range want {
+		if awarded[id] != score {
+			t.Errorf("submission %s awarded %d, want %d", id, awarded[id], score)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package scoreboard
+
+import (
+	model "OJ-backend/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// judgedBy keeps the judged submissions made up to and including at. A nil at keeps all of them.
+func judgedBy(submissions []model.Submission, at *time.Time) []model.Submission {
+	judged := make([]model.Submission, 0, len(submissions))
+	for _, submission := range submissions {
+		if submission.Status != model.StatusJudged {
+			continue
+		}
+		if at != nil && submission.SubmittedAt.After(*at) {
+			continue
+		}
+		judged = append(judged, submission)
+	}
+	return judged
+}
+
+// LoadAt rebuilds the scoreboard of a contest as it stood at the given time,
+// from the judged submissions made up to then
+func LoadAt(db *gorm.DB, contest model.Contest, at time.Time) (model.Scoreboard, error) {
+	data, err := load(db, contest)
+	if err != nil {
+		return model.Scoreboard{}, err
+	}
+
+	return Compute(contest, data.problems, data.participants, judgedBy(data.submissions, &at), nil), nil
+}
+
+// LoadHistory returns the rank changes of a contest built from its judged submissions
+func LoadHistory(db *gorm.DB, contest model.Contest) (model.StandingsHistory, error) {
+	data, err := load(db, contest)
+	if err != nil {
+		return model.StandingsHistory{}, err
+	}
+
+	return History(contest, data.problems, data.participants, data.submissions), nil
+}
+
+// History replays judged submissions in order and records a change for every
+// participant whose rank or results moved after each one
+func History(contest model.Contest, problems []model.ContestProblem, participants []model.ParticipantEntry, submissions []model.Submission) model.StandingsHistory {
+	board := newScoreboard(contest, problems, nil)
+	history := model.StandingsHistory{
+		ContestID:   contest.ID,
+		ScoringMode: board.ScoringMode,
+		Changes:     []model.RankChange{},
+	}
+
+	entries := buildEntries(contest, board.ScoringMode, problems, participants, nil)
+	rank(board.ScoringMode, entries)
+
+	column := make(map[uuid.UUID]int, len(problems))
+	for i, problem := range problems {
+		column[problem.ProblemID] = i
+	}
+
+	previous := make(map[uuid.UUID]model.RankChange, len(entries))
+	record := func(at time.Time) {
+		for _, entry := range entries {
+			change := model.RankChange{
+				At:         at,
+				Minute:     int(at.Sub(contest.StartTime).Minutes()),
+				UserID:     entry.UserID,
+				Username:   entry.Username,
+				Rank:       entry.Rank,
+				Solved:     entry.Solved,
+				Penalty:    entry.Penalty,
+				TotalScore: entry.TotalScore,
+			}
+			if last, ok := previous[entry.UserID]; ok && sameStanding(last, change) {
+				continue
+			}
+			previous[entry.UserID] = change
+			history.Changes = append(history.Changes, change)
+		}
+	}
+	record(contest.StartTime)
+
+	cells := make(map[cellKey][]model.Submission)
+	for _, submission := range judgedBy(submissions, nil) {
+		j, ok := column[submission.ProblemID]
+		if !ok {
+			continue
+		}
+		i := indexOf(entries, submission.UserID)
+		if i < 0 {
+			continue
+		}
+
+		// Only the submitting participant's cell changes, so recompute just that one
+		key := cellKey{submission.UserID, submission.ProblemID}
+		cells[key] = append(cells[key], submission)
+		result := cellResult(contest, submission.UserID, submission.ProblemID, cells[key], nil)
+		result.Score = cellScore(contest, board.ScoringMode, problems[j], result)
+		entries[i].Problems[j] = result
+		summarize(&entries[i])
+		rank(board.ScoringMode, entries)
+
+		record(submission.SubmittedAt)
+	}
+
+	return history
+}
+
+// sameStanding reports whether two points show the same rank and results
+func sameStanding(a, b model.RankChange) bool {
+	return a.Rank == b.Rank && a.Solved == b.Solved && a.Penalty == b.Penalty && a.TotalScore == b.TotalScore
+}
+
+// indexOf returns the index of a user's entry, or -1 if they have none
+func indexOf(entries []model.LeaderboardEntry, userID uuid.UUID) int {
+	for i, entry := range entries {
+		if entry.UserID == userID {
+			return i
+		}
+	}
+	return -1
+}
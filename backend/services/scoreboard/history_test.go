@@ -0,0 +1,76 @@
+package scoreboard
+
+import (
+	model "OJ-backend/models"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestHistory(t *testing.T) {
+	problems := testProblems(2, 100)
+	a, b := problems[0], problems[1]
+	participants, users := testUsers("alice", "bob")
+	alice, bob := users["alice"], users["bob"]
+
+	submissions := []model.Submission{
+		judged(bob, a, 5, "AC", 100),
+		// Changes nothing: bob already solved it
+		judged(bob, a, 6, "WA", 0),
+		judged(alice, a, 10, "AC", 100),
+		// Not ranked, not judged yet and not in the contest
+		judged(uuid.New(), a, 11, "AC", 100),
+		queued(alice, b, 12),
+		judged(alice, model.ContestProblem{ProblemID: uuid.New()}, 13, "AC", 100),
+		judged(alice, b, 20, "AC", 100),
+	}
+
+	history := History(testContest(model.ScoringICPC), problems, participants, submissions)
+
+	want := []struct {
+		userID  uuid.UUID
+		minute  int
+		rank    int
+		solved  int
+		penalty int
+	}{
+		// Everyone is tied at the start
+		{alice, 0, 1, 0, 0},
+		{bob, 0, 1, 0, 0},
+		{bob, 5, 1, 1, 5},
+		{alice, 5, 2, 0, 0},
+		{alice, 10, 2, 1, 10},
+		{alice, 20, 1, 2, 30},
+		{bob, 20, 2, 1, 5},
+	}
+
+	if len(history.Changes) != len(want) {
+		t.Fatalf("got %d changes, want %d: %+v", len(history.Changes), len(want), history.Changes)
+	}
+	for i, w := range want {
+		got := history.Changes[i]
+		if got.UserID != w.userID || got.Minute != w.minute || got.Rank != w.rank || got.Solved != w.solved || got.Penalty != w.penalty {
+			t.Errorf("change %d = %+v, want %+v", i, got, w)
+		}
+	}
+}
+
+func TestHistoryDynamic(t *testing.T) {
+	problems := testProblems(1, 1000)
+	a := problems[0]
+	participants, users := testUsers("alice")
+	alice := users["alice"]
+
+	history := History(dynamicContest(), problems, participants, []model.Submission{
+		judged(alice, a, 5, "WA", 0),
+		judged(alice, a, 10, "AC", 100),
+	})
+
+	if history.ScoringMode != model.ScoringDynamic {
+		t.Fatalf("scoring mode %q, want %q", history.ScoringMode, model.ScoringDynamic)
+	}
+	last := history.Changes[len(history.Changes)-1]
+	if want := 1000 - 40 - 50; last.TotalScore != want || last.Solved != 1 {
+		t.Errorf("last change = %+v, want one solve worth %d", last, want)
+	}
+}
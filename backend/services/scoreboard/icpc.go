@@ -0,0 +1,134 @@
+package scoreboard
+
+import (
+	model "OJ-backend/models"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// PenaltyMinutes is the penalty added for each rejected attempt before a problem is solved
+const PenaltyMinutes = 20
+
+// countsAsAttempt reports whether a verdict counts towards ICPC attempts.
+// Compile errors are not charged a penalty.
+func countsAsAttempt(result string) bool {
+	return result != "CE"
+}
+
+// cellKey identifies a participant's result on one problem
+type cellKey struct {
+	userID    uuid.UUID
+	problemID uuid.UUID
+}
+
+// computeICPC scores participants by problems solved and penalty minutes: the
+// minutes from contest start to each first accepted submission plus
+// PenaltyMinutes for every rejected attempt before it
+func computeICPC(contest model.Contest, problems []model.ContestProblem, participants []model.ParticipantEntry, submissions []model.Submission) []model.LeaderboardEntry {
+	cells := icpcCells(contest, submissions)
+	markFirstToSolve(cells, participants)
+
+	return buildEntries(contest, model.ScoringICPC, problems, participants, cells)
+}
+
+// icpcCells counts each participant's attempts on each problem up to their
+// first accepted submission. Submissions must be ordered by submission time.
+func icpcCells(contest model.Contest, submissions []model.Submission) map[cellKey]*model.ProblemResult {
+	cells := make(map[cellKey]*model.ProblemResult)
+
+	for _, submission := range submissions {
+		if !countsAsAttempt(submission.Result) {
+			continue
+		}
+
+		key := cellKey{submission.UserID, submission.ProblemID}
+		cell, ok := cells[key]
+		if !ok {
+			cell = &model.ProblemResult{ProblemID: submission.ProblemID}
+			cells[key] = cell
+		}
+		// Attempts after the first accepted one change nothing
+		if cell.Solved {
+			continue
+		}
+
+		cell.Attempts++
+		if submission.Result != "AC" {
+			continue
+		}
+
+		solvedAt := submission.SubmittedAt
+		cell.Solved = true
+		cell.SolvedAt = &solvedAt
+		cell.SolvedAtMinutes = int(submission.SubmittedAt.Sub(contest.StartTime).Minutes())
+		cell.Penalty = cell.SolvedAtMinutes + (cell.Attempts-1)*PenaltyMinutes
+	}
+
+	return cells
+}
+
+// buildEntries lays out each participant's cells in problem order, working out
+// what each is worth with cellScore
+func buildEntries(contest model.Contest, mode string, problems []model.ContestProblem, participants []model.ParticipantEntry, cells map[cellKey]*model.ProblemResult) []model.LeaderboardEntry {
+	entries := make([]model.LeaderboardEntry, 0, len(participants))
+	for _, participant := range participants {
+		entry := model.LeaderboardEntry{
+			UserID:   participant.UserID,
+			Username: participant.Username,
+			Problems: make([]model.ProblemResult, 0, len(problems)),
+		}
+
+		for _, problem := range problems {
+			result := model.ProblemResult{ProblemID: problem.ProblemID}
+			if cell, ok := cells[cellKey{participant.UserID, problem.ProblemID}]; ok {
+				result = *cell
+				result.Score = cellScore(contest, mode, problem, *cell)
+			}
+			entry.Problems = append(entry.Problems, result)
+		}
+
+		summarize(&entry)
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// lastSolvedMinutes returns when the entry's latest solve happened
+func lastSolvedMinutes(entry model.LeaderboardEntry) int {
+	last := 0
+	for _, result := range entry.Problems {
+		if result.Solved {
+			last = max(last, result.SolvedAtMinutes)
+		}
+	}
+	return last
+}
+
+// rankICPC orders entries by problems solved, then by penalty. Earlier last
+// solves break ties in the listing order, but participants with the same
+// solved count and penalty still share a rank.
+func rankICPC(entries []model.LeaderboardEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Solved != b.Solved {
+			return a.Solved > b.Solved
+		}
+		if a.Penalty != b.Penalty {
+			return a.Penalty < b.Penalty
+		}
+		if lastA, lastB := lastSolvedMinutes(a), lastSolvedMinutes(b); lastA != lastB {
+			return lastA < lastB
+		}
+		return a.Username < b.Username
+	})
+
+	for i := range entries {
+		if i > 0 && entries[i].Solved == entries[i-1].Solved && entries[i].Penalty == entries[i-1].Penalty {
+			entries[i].Rank = entries[i-1].Rank
+		} else {
+			entries[i].Rank = i + 1
+		}
+	}
+}
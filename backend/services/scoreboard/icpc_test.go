@@ -0,0 +1,113 @@
+package scoreboard
+
+import (
+	model "OJ-backend/models"
+	"testing"
+)
+
+// icpcEntry returns an entry with the given totals whose last solve happened at lastSolve
+func icpcEntry(username string, solved, penalty, lastSolve int) model.LeaderboardEntry {
+	entry := model.LeaderboardEntry{Username: username, Solved: solved, Penalty: penalty}
+	if solved > 0 {
+		entry.Problems = []model.ProblemResult{{Solved: true, SolvedAtMinutes: lastSolve}}
+	}
+	return entry
+}
+
+func TestRankICPC(t *testing.T) {
+	type ranked struct {
+		username string
+		rank     int
+	}
+
+	tests := []struct {
+		name    string
+		entries []model.LeaderboardEntry
+		want    []ranked
+	}{
+		{
+			name: "more solved problems rank higher",
+			entries: []model.LeaderboardEntry{
+				icpcEntry("alice", 1, 10, 10),
+				icpcEntry("bob", 2, 300, 200),
+			},
+			want: []ranked{{"bob", 1}, {"alice", 2}},
+		},
+		{
+			name: "less penalty ranks higher",
+			entries: []model.LeaderboardEntry{
+				icpcEntry("alice", 2, 120, 70),
+				icpcEntry("bob", 2, 100, 90),
+			},
+			want: []ranked{{"bob", 1}, {"alice", 2}},
+		},
+		{
+			name: "equal results share a rank but the earlier last solve is listed first",
+			entries: []model.LeaderboardEntry{
+				icpcEntry("alice", 2, 100, 80),
+				icpcEntry("bob", 2, 100, 60),
+			},
+			want: []ranked{{"bob", 1}, {"alice", 1}},
+		},
+		{
+			name: "fully equal entries are listed by username",
+			entries: []model.LeaderboardEntry{
+				icpcEntry("carol", 1, 30, 30),
+				icpcEntry("alice", 1, 30, 30),
+				icpcEntry("bob", 1, 30, 30),
+			},
+			want: []ranked{{"alice", 1}, {"bob", 1}, {"carol", 1}},
+		},
+		{
+			name: "ranks after a tie skip the shared places",
+			entries: []model.LeaderboardEntry{
+				icpcEntry("dave", 0, 0, 0),
+				icpcEntry("carol", 1, 50, 50),
+				icpcEntry("bob", 2, 90, 60),
+				icpcEntry("alice", 2, 90, 60),
+			},
+			want: []ranked{{"alice", 1}, {"bob", 1}, {"carol", 3}, {"dave", 4}},
+		},
+		{
+			name: "participants without solves tie at the bottom",
+			entries: []model.LeaderboardEntry{
+				icpcEntry("bob", 0, 0, 0),
+				icpcEntry("alice", 0, 0, 0),
+			},
+			want: []ranked{{"alice", 1}, {"bob", 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rankICPC(tt.entries)
+
+			for i, want := range tt.want {
+				if got := tt.entries[i]; got.Username != want.username || got.Rank != want.rank {
+					t.Errorf("entry %d is %s ranked %d, want %s ranked %d", i, got.Username, got.Rank, want.username, want.rank)
+				}
+			}
+		})
+	}
+}
+
+func TestRankIOI(t *testing.T) {
+	entries := []model.LeaderboardEntry{
+		{Username: "carol", TotalScore: 150},
+		{Username: "bob", TotalScore: 200},
+		{Username: "dave", TotalScore: 0},
+		{Username: "alice", TotalScore: 150},
+	}
+
+	rankIOI(entries)
+
+	want := []struct {
+		username string
+		rank     int
+	}{{"bob", 1}, {"alice", 2}, {"carol", 2}, {"dave", 4}}
+	for i, w := range want {
+		if got := entries[i]; got.Username != w.username || got.Rank != w.rank {
+			t.Errorf("entry %d is %s ranked %d, want %s ranked %d", i, got.Username, got.Rank, w.username, w.rank)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package scoreboard
+
+import (
+	model "OJ-backend/models"
+	"sort"
+)
+
+// computeIOI scores participants by the sum of their best score on each
+// problem, so resubmitting an already scored problem never adds to the total
+func computeIOI(contest model.Contest, problems []model.ContestProblem, participants []model.ParticipantEntry, submissions []model.Submission) []model.LeaderboardEntry {
+	cells := make(map[cellKey]*model.ProblemResult)
+
+	for _, submission := range submissions {
+		key := cellKey{submission.UserID, submission.ProblemID}
+		cell, ok := cells[key]
+		if !ok {
+			cell = &model.ProblemResult{ProblemID: submission.ProblemID}
+			cells[key] = cell
+		}
+
+		cell.Attempts++
+		if submission.Score > cell.Score {
+			cell.Score = submission.Score
+		}
+		if submission.Result == "AC" && !cell.Solved {
+			solvedAt := submission.SubmittedAt
+			cell.Solved = true
+			cell.SolvedAt = &solvedAt
+		}
+	}
+
+	return buildEntries(contest, model.ScoringIOI, problems, participants, cells)
+}
+
+// rankIOI orders entries by total score; equal totals share a rank
+func rankIOI(entries []model.LeaderboardEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].TotalScore != entries[j].TotalScore {
+			return entries[i].TotalScore > entries[j].TotalScore
+		}
+		return entries[i].Username < entries[j].Username
+	})
+
+	for i := range entries {
+		if i > 0 && entries[i].TotalScore == entries[i-1].TotalScore {
+			entries[i].Rank = entries[i-1].Rank
+		} else {
+			entries[i].Rank = i + 1
+		}
+	}
+}
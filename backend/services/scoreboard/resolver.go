@@ -0,0 +1,95 @@
+package scoreboard
+
+import (
+	model "OJ-backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// LoadResolver builds the reveal sequence that takes a contest's frozen
+// scoreboard to its final one. Contests without a freeze have no events.
+func LoadResolver(db *gorm.DB, contest model.Contest) (model.ResolverData, error) {
+	data, err := load(db, contest)
+	if err != nil {
+		return model.ResolverData{}, err
+	}
+
+	return Resolve(contest, data.problems, data.participants, data.submissions), nil
+}
+
+// Resolve replays the reveal the way an ICPC resolver does: the lowest ranked
+// participant with pending attempts has their leftmost pending cell revealed,
+// the scoreboard is re-ranked, and this repeats until nothing is pending.
+func Resolve(contest model.Contest, problems []model.ContestProblem, participants []model.ParticipantEntry, submissions []model.Submission) model.ResolverData {
+	frozen := Compute(contest, problems, participants, submissions, contest.FreezeTime())
+	final := Compute(contest, problems, participants, submissions, nil)
+
+	finalCells := make(map[cellKey]model.ProblemResult)
+	for _, entry := range final.Entries {
+		for _, result := range entry.Problems {
+			finalCells[cellKey{entry.UserID, result.ProblemID}] = result
+		}
+	}
+
+	// Work on a copy so the frozen scoreboard is returned untouched
+	entries := make([]model.LeaderboardEntry, len(frozen.Entries))
+	for i, entry := range frozen.Entries {
+		entry.Problems = append([]model.ProblemResult(nil), entry.Problems...)
+		entries[i] = entry
+	}
+
+	events := []model.ResolverEvent{}
+	for {
+		i, j := nextReveal(entries)
+		if i < 0 {
+			break
+		}
+
+		entry := &entries[i]
+		userID, username := entry.UserID, entry.Username
+		rankBefore := entry.Rank
+
+		revealed := finalCells[cellKey{userID, entry.Problems[j].ProblemID}]
+		// Attempts still being judged cannot be revealed yet
+		revealed.Pending = 0
+		entry.Problems[j] = revealed
+		summarize(entry)
+		// Re-ranking reorders entries, so entry must not be used after this
+		rank(frozen.ScoringMode, entries)
+
+		events = append(events, model.ResolverEvent{
+			Step:       len(events) + 1,
+			UserID:     userID,
+			Username:   username,
+			Result:     revealed,
+			RankBefore: rankBefore,
+			RankAfter:  rankOf(entries, userID),
+		})
+	}
+
+	return model.ResolverData{Frozen: frozen, Events: events, Final: final}
+}
+
+// nextReveal finds the lowest ranked entry with a pending cell and returns
+// its index and the index of its leftmost pending cell, or -1 if none is left
+func nextReveal(entries []model.LeaderboardEntry) (int, int) {
+	for i := len(entries) - 1; i >= 0; i-- {
+		for j, result := range entries[i].Problems {
+			if result.Pending > 0 {
+				return i, j
+			}
+		}
+	}
+	return -1, -1
+}
+
+// rankOf returns the rank of a user's entry
+func rankOf(entries []model.LeaderboardEntry, userID uuid.UUID) int {
+	for _, entry := range entries {
+		if entry.UserID == userID {
+			return entry.Rank
+		}
+	}
+	return 0
+}
@@ -0,0 +1,116 @@
+package scoreboard
+
+import (
+	model "OJ-backend/models"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestResolve(t *testing.T) {
+	problems := testProblems(2, 100)
+	a, b := problems[0], problems[1]
+	participants, users := testUsers("alice", "bob", "carol")
+	alice, bob, carol := users["alice"], users["bob"], users["carol"]
+
+	contest := testContest(model.ScoringICPC)
+	// The freeze starts four hours in
+	contest.FreezeMinutes = 60
+
+	submissions := []model.Submission{
+		judged(alice, a, 30, "AC", 100),
+		judged(bob, a, 250, "AC", 100),
+		judged(carol, a, 255, "WA", 0),
+		judged(bob, b, 260, "AC", 100),
+		queued(alice, b, 270),
+	}
+
+	data := Resolve(contest, problems, participants, submissions)
+
+	type event struct {
+		userID     uuid.UUID
+		problem    uuid.UUID
+		solved     bool
+		rankBefore int
+		rankAfter  int
+	}
+	// The lowest ranked participant with pending cells goes first, leftmost
+	// cell first. alice's attempt is still being judged, so revealing it shows
+	// nothing new.
+	want := []event{
+		{carol, a.ProblemID, false, 2, 2},
+		{bob, a.ProblemID, true, 2, 2},
+		{bob, b.ProblemID, true, 2, 1},
+		{alice, b.ProblemID, false, 2, 2},
+	}
+
+	if len(data.Events) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(data.Events), len(want), data.Events)
+	}
+	for i, w := range want {
+		got := data.Events[i]
+		if got.Step != i+1 || got.UserID != w.userID || got.Result.ProblemID != w.problem || got.Result.Solved != w.solved ||
+			got.RankBefore != w.rankBefore || got.RankAfter != w.rankAfter {
+			t.Errorf("event %d = %+v, want %+v", i, got, w)
+		}
+		if got.Result.Pending != 0 {
+			t.Errorf("event %d reveals a cell with %d pending attempts", i, got.Result.Pending)
+		}
+	}
+
+	// The frozen scoreboard is returned as it was before the reveal
+	if entry := entryOf(t, data.Frozen, bob); entry.Solved != 0 || entry.Problems[0].Pending != 1 || entry.Problems[1].Pending != 1 {
+		t.Errorf("frozen entry of bob = %+v, want two pending cells", entry)
+	}
+	// The final scoreboard still shows the attempt being judged
+	if entry := entryOf(t, data.Final, alice); entry.Problems[1].Pending != 1 {
+		t.Errorf("final entry of alice = %+v, want her queued attempt pending", entry)
+	}
+
+	if final := Compute(contest, problems, participants, submissions, nil); !reflect.DeepEqual(data.Final, final) {
+		t.Errorf("final scoreboard differs from the live one")
+	}
+}
+
+func TestResolveWithoutFreeze(t *testing.T) {
+	problems := testProblems(1, 100)
+	participants, users := testUsers("alice")
+
+	data := Resolve(testContest(model.ScoringICPC), problems, participants, []model.Submission{judged(users["alice"], problems[0], 250, "AC", 100)})
+
+	if len(data.Events) != 0 {
+		t.Errorf("got %d events for a contest without a freeze, want none", len(data.Events))
+	}
+	if !reflect.DeepEqual(data.Frozen, data.Final) {
+		t.Errorf("frozen and final scoreboards differ without a freeze")
+	}
+}
+
+func TestNextReveal(t *testing.T) {
+	pending := func(counts ...int) model.LeaderboardEntry {
+		entry := model.LeaderboardEntry{}
+		for _, count := range counts {
+			entry.Problems = append(entry.Problems, model.ProblemResult{Pending: count})
+		}
+		return entry
+	}
+
+	tests := []struct {
+		name    string
+		entries []model.LeaderboardEntry
+		i, j    int
+	}{
+		{"nothing pending", []model.LeaderboardEntry{pending(0, 0), pending(0, 0)}, -1, -1},
+		{"lowest ranked entry first", []model.LeaderboardEntry{pending(1, 0), pending(0, 2)}, 1, 1},
+		{"leftmost pending cell first", []model.LeaderboardEntry{pending(0, 1, 1), pending(0, 0, 0)}, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if i, j := nextReveal(tt.entries); i != tt.i || j != tt.j {
+				t.Errorf("nextReveal = (%d, %d), want (%d, %d)", i, j, tt.i, tt.j)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package scoreboard
+
+import (
+	model "OJ-backend/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// contestData is everything a contest's scoreboard is computed from
+type contestData struct {
+	problems     []model.ContestProblem
+	participants []model.ParticipantEntry
+	submissions  []model.Submission
+}
+
+// rankedStatuses are the statuses of submissions that appear on the scoreboard.
+// Submissions still being judged are included so they can be shown as pending.
+var rankedStatuses = append([]string{model.StatusJudged}, model.PendingStatuses...)
+
+// loadProblems fetches the problems of a contest in scoreboard column order
+func loadProblems(db *gorm.DB, contest model.Contest) ([]model.ContestProblem, error) {
+	var problems []model.ContestProblem
+	err := db.
+		Preload("Problem").
+		Where("contest_problems.contest_id = ?", contest.ID).
+		Order(model.ContestProblemOrder).
+		Find(&problems).Error
+	return problems, err
+}
+
+// loadParticipants fetches the ranked participants of a contest. Only
+// registered participants are ranked; disqualified users drop out.
+func loadParticipants(db *gorm.DB, contest model.Contest) ([]model.ParticipantEntry, error) {
+	var participants []model.ParticipantEntry
+	err := db.
+		Table("contest_users").
+		Select("contest_users.user_id, users.username, users.email, contest_users.status, contest_users.registered_at").
+		Joins("JOIN users ON contest_users.user_id = users.id").
+		Where("contest_users.contest_id = ? AND contest_users.status = ?", contest.ID, model.ParticipantRegistered).
+		Scan(&participants).Error
+	return participants, err
+}
+
+// load fetches the problems, ranked participants and ranked submissions of a contest
+func load(db *gorm.DB, contest model.Contest) (contestData, error) {
+	var data contestData
+	var err error
+
+	if data.problems, err = loadProblems(db, contest); err != nil {
+		return data, err
+	}
+	if data.participants, err = loadParticipants(db, contest); err != nil {
+		return data, err
+	}
+
+	err = db.
+		Where("contest_id = ? AND is_practice = ? AND status IN ?", contest.ID, false, rankedStatuses).
+		Order("submitted_at ASC").
+		Find(&data.submissions).Error
+
+	return data, err
+}
+
+// Load returns the scoreboard of a contest from the standings table, or from
+// the cache when it was built recently. The public scoreboard honours the
+// contest's freeze, while the live one, meant for admins, never freezes.
+func Load(db *gorm.DB, contest model.Contest, live bool) (model.Scoreboard, error) {
+	var frozenAt *time.Time
+	if !live && contest.IsFrozen(time.Now()) {
+		frozenAt = contest.FreezeTime()
+	}
+
+	key := cacheKey{contestID: contest.ID, frozen: frozenAt != nil}
+	if board, ok := cached(key); ok {
+		return board, nil
+	}
+
+	board, err := loadStandings(db, contest, frozenAt)
+	if err != nil {
+		return model.Scoreboard{}, err
+	}
+
+	store(key, board)
+	return board, nil
+}
+
+// newScoreboard returns an empty scoreboard with the contest's problem columns
+func newScoreboard(contest model.Contest, problems []model.ContestProblem, frozenAt *time.Time) model.Scoreboard {
+	board := model.Scoreboard{
+		ContestID:   contest.ID,
+		ScoringMode: contest.ScoringMode,
+		Problems:    make([]model.ScoreboardProblem, 0, len(problems)),
+		Frozen:      frozenAt != nil,
+		FrozenAt:    frozenAt,
+	}
+	if !model.IsValidScoringMode(board.ScoringMode) {
+		board.ScoringMode = model.ScoringICPC
+	}
+	for _, problem := range problems {
+		board.Problems = append(board.Problems, model.ScoreboardProblem{
+			ID:     problem.ProblemID,
+			Label:  problem.Label,
+			Title:  problem.Problem.Title,
+			Points: problem.Points,
+			Color:  problem.Color,
+		})
+	}
+
+	return board
+}
+
+// Compute ranks participants using the contest's scoring mode. Submissions
+// must be ordered by submission time. Those that are not judged yet, or were
+// made at or after frozenAt when it is set, only count as pending attempts.
+func Compute(contest model.Contest, problems []model.ContestProblem, participants []model.ParticipantEntry, submissions []model.Submission, frozenAt *time.Time) model.Scoreboard {
+	board := newScoreboard(contest, problems, frozenAt)
+
+	var visible, hidden []model.Submission
+	for _, submission := range submissions {
+		if submission.Status != model.StatusJudged || (frozenAt != nil && !submission.SubmittedAt.Before(*frozenAt)) {
+			hidden = append(hidden, submission)
+		} else {
+			visible = append(visible, submission)
+		}
+	}
+
+	switch board.ScoringMode {
+	case model.ScoringIOI:
+		board.Entries = computeIOI(contest, problems, participants, visible)
+	case model.ScoringDynamic:
+		board.Entries = computeDynamic(contest, problems, participants, visible)
+	default:
+		board.Entries = computeICPC(contest, problems, participants, visible)
+	}
+
+	markPending(board.Entries, problems, hidden)
+	rank(board.ScoringMode, board.Entries)
+
+	return board
+}
+
+// markPending counts hidden attempts on the cells they belong to. Attempts on
+// a problem that was already solved change nothing and are left out.
+func markPending(entries []model.LeaderboardEntry, problems []model.ContestProblem, hidden []model.Submission) {
+	column := make(map[uuid.UUID]int, len(problems))
+	for i, problem := range problems {
+		column[problem.ProblemID] = i
+	}
+	row := make(map[uuid.UUID]int, len(entries))
+	for i, entry := range entries {
+		row[entry.UserID] = i
+	}
+
+	for _, submission := range hidden {
+		i, ok := row[submission.UserID]
+		if !ok {
+			continue
+		}
+		j, ok := column[submission.ProblemID]
+		if !ok {
+			continue
+		}
+		cell := &entries[i].Problems[j]
+		if cell.Solved {
+			continue
+		}
+		cell.Pending++
+	}
+}
+
+// summarize recomputes an entry's totals from its problem results
+func summarize(entry *model.LeaderboardEntry) {
+	entry.Solved, entry.Penalty, entry.TotalScore = 0, 0, 0
+	for _, result := range entry.Problems {
+		if result.Solved {
+			entry.Solved++
+			entry.Penalty += result.Penalty
+		}
+		entry.TotalScore += result.Score
+	}
+}
+
+// cellScore returns what a cell is worth on the scoreboard. IOI judge scores
+// are scaled to the problem's points, and dynamic cells are scored from when
+// they were solved, so changing the points or the contest's settings never
+// needs the cells to be recomputed.
+func cellScore(contest model.Contest, mode string, problem model.ContestProblem, cell model.ProblemResult) int {
+	switch mode {
+	case model.ScoringIOI:
+		return problem.ScaleScore(cell.Score)
+	case model.ScoringDynamic:
+		return dynamicScore(contest, problem, cell)
+	}
+	return cell.Score
+}
+
+// rank orders entries and assigns their ranks using the scoring mode
+func rank(mode string, entries []model.LeaderboardEntry) {
+	switch mode {
+	case model.ScoringIOI, model.ScoringDynamic:
+		rankIOI(entries)
+	default:
+		rankICPC(entries)
+	}
+}
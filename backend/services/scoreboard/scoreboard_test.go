@@ -0,0 +1,293 @@
+package scoreboard
+
+import (
+	model "OJ-backend/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var contestStart = time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+
+// testContest returns a five hour contest using the given scoring mode
+func testContest(mode string) model.Contest {
+	return model.Contest{
+		ID:          uuid.New(),
+		StartTime:   contestStart,
+		EndTime:     contestStart.Add(5 * time.Hour),
+		ScoringMode: mode,
+	}
+}
+
+// testProblems returns contest problems labelled A, B and so on, worth points each
+func testProblems(count, points int) []model.ContestProblem {
+	problems := make([]model.ContestProblem, 0, count)
+	for i := 0; i < count; i++ {
+		problems = append(problems, model.ContestProblem{
+			ProblemID: uuid.New(),
+			Label:     model.ProblemLabel(i),
+			Points:    points,
+		})
+	}
+	return problems
+}
+
+// testUsers returns a participant for each username, along with their IDs by name
+func testUsers(usernames ...string) ([]model.ParticipantEntry, map[string]uuid.UUID) {
+	participants := make([]model.ParticipantEntry, 0, len(usernames))
+	ids := make(map[string]uuid.UUID, len(usernames))
+	for _, username := range usernames {
+		id := uuid.New()
+		ids[username] = id
+		participants = append(participants, model.ParticipantEntry{UserID: id, Username: username, Status: model.ParticipantRegistered})
+	}
+	return participants, ids
+}
+
+// judged returns a judged submission made the given number of minutes into the contest
+func judged(userID uuid.UUID, problem model.ContestProblem, minute int, result string, score int) model.Submission {
+	return model.Submission{
+		ID:          uuid.New(),
+		UserID:      userID,
+		ProblemID:   problem.ProblemID,
+		SubmittedAt: contestStart.Add(time.Duration(minute) * time.Minute),
+		Status:      model.StatusJudged,
+		Result:      result,
+		Score:       score,
+	}
+}
+
+// queued returns a submission still waiting for its verdict
+func queued(userID uuid.UUID, problem model.ContestProblem, minute int) model.Submission {
+	submission := judged(userID, problem, minute, "pending", 0)
+	submission.Status = model.StatusQueued
+	return submission
+}
+
+// entryOf returns a user's entry, failing the test if they have none
+func entryOf(t *testing.T, board model.Scoreboard, userID uuid.UUID) model.LeaderboardEntry {
+	t.Helper()
+	for _, entry := range board.Entries {
+		if entry.UserID == userID {
+			return entry
+		}
+	}
+	t.Fatalf("no entry for user %s", userID)
+	return model.LeaderboardEntry{}
+}
+
+func TestComputeICPCCells(t *testing.T) {
+	problems := testProblems(1, 100)
+	a := problems[0]
+	participants, users := testUsers("alice")
+	alice := users["alice"]
+	freezeAt := contestStart.Add(60 * time.Minute)
+
+	tests := []struct {
+		name        string
+		submissions []model.Submission
+		frozenAt    *time.Time
+		want        model.ProblemResult
+	}{
+		{
+			name:        "no attempts",
+			submissions: nil,
+			want:        model.ProblemResult{},
+		},
+		{
+			name:        "solved on the first attempt",
+			submissions: []model.Submission{judged(alice, a, 17, "AC", 100)},
+			want:        model.ProblemResult{Attempts: 1, Solved: true, SolvedAtMinutes: 17, Penalty: 17, FirstToSolve: true},
+		},
+		{
+			name: "rejected attempts add penalty but compile errors do not",
+			submissions: []model.Submission{
+				judged(alice, a, 5, "WA", 0),
+				judged(alice, a, 8, "CE", 0),
+				judged(alice, a, 12, "TLE", 0),
+				judged(alice, a, 20, "AC", 100),
+			},
+			want: model.ProblemResult{Attempts: 3, Solved: true, SolvedAtMinutes: 20, Penalty: 20 + 2*PenaltyMinutes, FirstToSolve: true},
+		},
+		{
+			name: "attempts after the solve change nothing",
+			submissions: []model.Submission{
+				judged(alice, a, 5, "AC", 100),
+				judged(alice, a, 6, "WA", 0),
+				queued(alice, a, 7),
+			},
+			want: model.ProblemResult{Attempts: 1, Solved: true, SolvedAtMinutes: 5, Penalty: 5, FirstToSolve: true},
+		},
+		{
+			name:        "unsolved attempts",
+			submissions: []model.Submission{judged(alice, a, 5, "WA", 0), judged(alice, a, 9, "RE", 0)},
+			want:        model.ProblemResult{Attempts: 2},
+		},
+		{
+			name:        "submissions being judged are pending",
+			submissions: []model.Submission{judged(alice, a, 5, "WA", 0), queued(alice, a, 9)},
+			want:        model.ProblemResult{Attempts: 1, Pending: 1},
+		},
+		{
+			name:        "the freeze hides verdicts from the freeze on",
+			submissions: []model.Submission{judged(alice, a, 30, "WA", 0), judged(alice, a, 60, "AC", 100), judged(alice, a, 70, "WA", 0)},
+			frozenAt:    &freezeAt,
+			want:        model.ProblemResult{Attempts: 1, Pending: 2},
+		},
+		{
+			name:        "the freeze keeps solves made before it",
+			submissions: []model.Submission{judged(alice, a, 59, "AC", 100), judged(alice, a, 70, "WA", 0)},
+			frozenAt:    &freezeAt,
+			want:        model.ProblemResult{Attempts: 1, Solved: true, SolvedAtMinutes: 59, Penalty: 59, FirstToSolve: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			board := Compute(testContest(model.ScoringICPC), problems, participants, tt.submissions, tt.frozenAt)
+
+			got := entryOf(t, board, alice).Problems[0]
+			got.SolvedAt = nil
+			tt.want.ProblemID = a.ProblemID
+			if got != tt.want {
+				t.Errorf("cell = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComputeFirstToSolve(t *testing.T) {
+	problems := testProblems(2, 100)
+	a, b := problems[0], problems[1]
+	participants, users := testUsers("alice", "bob", "carol")
+	alice, bob, carol := users["alice"], users["bob"], users["carol"]
+	// mallory is not a ranked participant, e.g. unregistered or disqualified
+	mallory := uuid.New()
+
+	submissions := []model.Submission{
+		judged(mallory, a, 1, "AC", 100),
+		judged(alice, b, 2, "WA", 0),
+		judged(bob, a, 3, "AC", 100),
+		judged(carol, b, 4, "AC", 100),
+		judged(alice, b, 4, "AC", 100),
+		judged(alice, a, 5, "AC", 100),
+	}
+
+	board := Compute(testContest(model.ScoringICPC), problems, participants, submissions, nil)
+
+	if len(board.Entries) != len(participants) {
+		t.Fatalf("got %d entries, want %d", len(board.Entries), len(participants))
+	}
+
+	tests := []struct {
+		name    string
+		userID  uuid.UUID
+		problem int
+		want    bool
+	}{
+		{"earliest ranked solver when an unranked user solved first", bob, 0, true},
+		{"later solver", alice, 0, false},
+		{"solvers at the same moment are both first", carol, 1, true},
+		{"solver at the same moment after a rejected attempt", alice, 1, true},
+		{"no solve", bob, 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := entryOf(t, board, tt.userID).Problems[tt.problem].FirstToSolve; got != tt.want {
+				t.Errorf("FirstToSolve = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkFirstToSolveSkipsUnrankedCells(t *testing.T) {
+	problems := testProblems(1, 100)
+	a := problems[0]
+	participants, users := testUsers("alice")
+	alice := users["alice"]
+	disqualified := uuid.New()
+
+	early, late := contestStart.Add(time.Minute), contestStart.Add(time.Hour)
+	cells := map[cellKey]*model.ProblemResult{
+		{disqualified, a.ProblemID}: {ProblemID: a.ProblemID, Solved: true, SolvedAt: &early},
+		{alice, a.ProblemID}:        {ProblemID: a.ProblemID, Solved: true, SolvedAt: &late},
+	}
+
+	markFirstToSolve(cells, participants)
+
+	if !cells[cellKey{alice, a.ProblemID}].FirstToSolve {
+		t.Error("ranked solver was not marked first to solve")
+	}
+	if cells[cellKey{disqualified, a.ProblemID}].FirstToSolve {
+		t.Error("unranked solver was marked first to solve")
+	}
+}
+
+func TestComputeIOI(t *testing.T) {
+	problems := testProblems(2, 50)
+	a, b := problems[0], problems[1]
+	participants, users := testUsers("alice", "bob", "carol")
+	alice, bob, carol := users["alice"], users["bob"], users["carol"]
+
+	submissions := []model.Submission{
+		judged(alice, a, 1, "WA", 40),
+		judged(alice, a, 2, "AC", 100),
+		judged(alice, a, 3, "WA", 60),
+		judged(bob, a, 4, "WA", 60),
+		judged(bob, b, 5, "CE", 0),
+		judged(bob, b, 6, "WA", 80),
+		judged(carol, b, 7, "AC", 100),
+	}
+
+	board := Compute(testContest(model.ScoringIOI), problems, participants, submissions, nil)
+
+	tests := []struct {
+		userID   uuid.UUID
+		scores   []int
+		attempts []int
+		total    int
+	}{
+		// Best scores are kept and scaled from the judge's 100 to the problem's 50 points
+		{alice, []int{50, 0}, []int{3, 0}, 50},
+		{carol, []int{0, 50}, []int{0, 1}, 50},
+		{bob, []int{30, 40}, []int{1, 2}, 70},
+	}
+
+	for _, tt := range tests {
+		entry := entryOf(t, board, tt.userID)
+		for j, result := range entry.Problems {
+			if result.Score != tt.scores[j] || result.Attempts != tt.attempts[j] {
+				t.Errorf("%s problem %s: score %d after %d attempts, want %d after %d", entry.Username, problems[j].Label, result.Score, result.Attempts, tt.scores[j], tt.attempts[j])
+			}
+		}
+		if entry.TotalScore != tt.total {
+			t.Errorf("%s: total %d, want %d", entry.Username, entry.TotalScore, tt.total)
+		}
+	}
+
+	wantOrder := []struct {
+		username string
+		rank     int
+	}{{"bob", 1}, {"alice", 2}, {"carol", 2}}
+	for i, want := range wantOrder {
+		if got := board.Entries[i]; got.Username != want.username || got.Rank != want.rank {
+			t.Errorf("entry %d is %s ranked %d, want %s ranked %d", i, got.Username, got.Rank, want.username, want.rank)
+		}
+	}
+}
+
+func TestComputeUnknownModeFallsBackToICPC(t *testing.T) {
+	problems := testProblems(1, 100)
+	participants, users := testUsers("alice")
+
+	board := Compute(testContest("unknown"), problems, participants, []model.Submission{judged(users["alice"], problems[0], 10, "AC", 100)}, nil)
+
+	if board.ScoringMode != model.ScoringICPC {
+		t.Fatalf("scoring mode %q, want %q", board.ScoringMode, model.ScoringICPC)
+	}
+	if entry := board.Entries[0]; entry.Solved != 1 || entry.Penalty != 10 || entry.TotalScore != 0 {
+		t.Errorf("entry = %+v, want one ICPC solve with 10 penalty minutes", entry)
+	}
+}
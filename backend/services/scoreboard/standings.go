@@ -0,0 +1,173 @@
+package scoreboard
+
+import (
+	model "OJ-backend/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// cellResult computes one participant's result on one problem from their
+// ranked submissions on it, ordered by submission time. The score stays on the
+// judge's scale so the scoreboard can scale it to the problem's current points.
+func cellResult(contest model.Contest, userID, problemID uuid.UUID, submissions []model.Submission, frozenAt *time.Time) model.ProblemResult {
+	problems := []model.ContestProblem{{ProblemID: problemID, Points: model.JudgeMaxScore}}
+	participants := []model.ParticipantEntry{{UserID: userID}}
+
+	return Compute(contest, problems, participants, submissions, frozenAt).Entries[0].Problems[0]
+}
+
+// cellResults computes a cell's live result and its result as the public sees it during the freeze
+func cellResults(contest model.Contest, userID, problemID uuid.UUID, submissions []model.Submission) (model.ProblemResult, model.ProblemResult) {
+	live := cellResult(contest, userID, problemID, submissions, nil)
+	frozen := live
+	if freezeAt := contest.FreezeTime(); freezeAt != nil {
+		frozen = cellResult(contest, userID, problemID, submissions, freezeAt)
+	}
+
+	return live, frozen
+}
+
+// Refresh recomputes a participant's standing on one problem of a contest.
+// Call it in the transaction that changes one of their submissions.
+func Refresh(tx *gorm.DB, contestID, userID, problemID uuid.UUID) error {
+	var contest model.Contest
+	if err := tx.First(&contest, "id = ?", contestID).Error; err != nil {
+		return err
+	}
+
+	// Lock the standing so concurrent refreshes of the same cell run one after
+	// the other, each seeing the submissions the previous one committed
+	standing := model.Standing{ContestID: contestID, UserID: userID, ProblemID: problemID}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&standing).Error; err != nil {
+		return err
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&standing, "contest_id = ? AND user_id = ? AND problem_id = ?", contestID, userID, problemID).Error; err != nil {
+		return err
+	}
+
+	var submissions []model.Submission
+	if err := tx.
+		Where("contest_id = ? AND user_id = ? AND problem_id = ? AND is_practice = ? AND status IN ?", contestID, userID, problemID, false, rankedStatuses).
+		Order("submitted_at ASC").
+		Find(&submissions).Error; err != nil {
+		return err
+	}
+
+	standing.SetResults(cellResults(contest, userID, problemID, submissions))
+	return tx.Save(&standing).Error
+}
+
+// Rebuild recomputes every standing of a contest from its submissions, and
+// the scores stored on its accepted submissions
+func Rebuild(db *gorm.DB, contest model.Contest) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var submissions []model.Submission
+		if err := tx.
+			Where("contest_id = ? AND is_practice = ? AND status IN ?", contest.ID, false, rankedStatuses).
+			Order("submitted_at ASC").
+			Find(&submissions).Error; err != nil {
+			return err
+		}
+
+		problems, err := loadProblems(tx, contest)
+		if err != nil {
+			return err
+		}
+		if err := rescore(tx, contest, problems, submissions); err != nil {
+			return err
+		}
+
+		cells := make(map[cellKey][]model.Submission)
+		for _, submission := range submissions {
+			key := cellKey{submission.UserID, submission.ProblemID}
+			cells[key] = append(cells[key], submission)
+		}
+
+		if err := tx.Where("contest_id = ?", contest.ID).Delete(&model.Standing{}).Error; err != nil {
+			return err
+		}
+
+		standings := make([]model.Standing, 0, len(cells))
+		for key, cellSubmissions := range cells {
+			standing := model.Standing{ContestID: contest.ID, UserID: key.userID, ProblemID: key.problemID}
+			standing.SetResults(cellResults(contest, key.userID, key.problemID, cellSubmissions))
+			standings = append(standings, standing)
+		}
+		if len(standings) == 0 {
+			return nil
+		}
+
+		return tx.CreateInBatches(standings, 500).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	Invalidate(contest.ID)
+	return nil
+}
+
+// loadStandings builds a scoreboard from the standings table
+func loadStandings(db *gorm.DB, contest model.Contest, frozenAt *time.Time) (model.Scoreboard, error) {
+	problems, err := loadProblems(db, contest)
+	if err != nil {
+		return model.Scoreboard{}, err
+	}
+	participants, err := loadParticipants(db, contest)
+	if err != nil {
+		return model.Scoreboard{}, err
+	}
+
+	var standings []model.Standing
+	if err := db.Where("contest_id = ?", contest.ID).Find(&standings).Error; err != nil {
+		return model.Scoreboard{}, err
+	}
+
+	board := newScoreboard(contest, problems, frozenAt)
+
+	cells := make(map[cellKey]*model.ProblemResult, len(standings))
+	for _, standing := range standings {
+		result := standing.Result(frozenAt != nil)
+		cells[cellKey{standing.UserID, standing.ProblemID}] = &result
+	}
+
+	if board.ScoringMode == model.ScoringICPC {
+		markFirstToSolve(cells, participants)
+	}
+
+	board.Entries = buildEntries(contest, board.ScoringMode, problems, participants, cells)
+	rank(board.ScoringMode, board.Entries)
+
+	return board, nil
+}
+
+// markFirstToSolve flags the earliest solve of each problem among ranked
+// participants, so the marker never goes to someone left off the scoreboard.
+// Participants who solved a problem at the same moment are all flagged.
+func markFirstToSolve(cells map[cellKey]*model.ProblemResult, participants []model.ParticipantEntry) {
+	ranked := make(map[uuid.UUID]bool, len(participants))
+	for _, participant := range participants {
+		ranked[participant.UserID] = true
+	}
+
+	first := make(map[uuid.UUID]time.Time)
+	for key, cell := range cells {
+		if !ranked[key.userID] || !cell.Solved || cell.SolvedAt == nil {
+			continue
+		}
+		if current, ok := first[key.problemID]; !ok || cell.SolvedAt.Before(current) {
+			first[key.problemID] = *cell.SolvedAt
+		}
+	}
+
+	for key, cell := range cells {
+		if !ranked[key.userID] || !cell.Solved || cell.SolvedAt == nil {
+			continue
+		}
+		cell.FirstToSolve = cell.SolvedAt.Equal(first[key.problemID])
+	}
+}
@@ -0,0 +1,28 @@
+package scoreboard
+
+import (
+	model "OJ-backend/models"
+	"OJ-backend/services/sse"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Loader returns a loader that computes a contest's current scoreboard for leaderboard streams
+func Loader(db *gorm.DB, contestID uuid.UUID) sse.ScoreboardLoader {
+	return func(live bool) (model.Scoreboard, error) {
+		var contest model.Contest
+		if err := db.First(&contest, "id = ?", contestID).Error; err != nil {
+			return model.Scoreboard{}, err
+		}
+		return Load(db, contest, live)
+	}
+}
+
+// Publish drops a contest's cached scoreboards and pushes its changed
+// standings to leaderboard subscribers in the background. Call it once a
+// change to the standings has been committed.
+func Publish(db *gorm.DB, contestID uuid.UUID) {
+	Invalidate(contestID)
+	go sse.GlobalLeaderboardManager.Publish(contestID.String(), Loader(db, contestID))
+}
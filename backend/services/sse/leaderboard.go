@@ -0,0 +1,287 @@
+package sse
+
+import (
+	model "OJ-backend/models"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// heartbeatInterval is how often idle leaderboard streams get a comment line so proxies keep them open
+const heartbeatInterval = 30 * time.Second
+
+// ScoreboardLoader computes a contest's scoreboard, either live or as the public sees it
+type ScoreboardLoader func(live bool) (model.Scoreboard, error)
+
+// LeaderboardUpdate is sent to leaderboard subscribers. A snapshot carries the
+// whole scoreboard; a delta only the entries that changed since the last update.
+type LeaderboardUpdate struct {
+	Type       string                   `json:"type"` // "snapshot" or "delta"
+	ContestID  string                   `json:"contest_id"`
+	Scoreboard *model.Scoreboard        `json:"scoreboard,omitempty"`
+	Changed    []model.LeaderboardEntry `json:"changed,omitempty"`
+	Removed    []uuid.UUID              `json:"removed,omitempty"`
+}
+
+// LeaderboardClient is a single leaderboard stream
+type LeaderboardClient struct {
+	ContestID      string
+	Live           bool
+	ResponseWriter http.ResponseWriter
+	Done           chan bool
+
+	// writeMu guards writes to ResponseWriter; closed is set under it once the
+	// handler has returned, after which the writer must not be touched
+	writeMu sync.Mutex
+	closed  bool
+}
+
+// leaderboardKey identifies the subscribers of one view of a contest's scoreboard
+type leaderboardKey struct {
+	contestID string
+	live      bool
+}
+
+// LeaderboardManager fans scoreboard changes out to contest subscribers
+type LeaderboardManager struct {
+	mu      sync.RWMutex
+	clients map[leaderboardKey]map[*LeaderboardClient]bool
+	last    map[leaderboardKey]model.Scoreboard
+
+	// syncMu holds one lock per contest, serializing computing its scoreboard
+	// with sending it, so deltas are always taken against what subscribers last
+	// saw. Other contests are not held up.
+	syncMu map[string]*sync.Mutex
+}
+
+var GlobalLeaderboardManager = &LeaderboardManager{
+	clients: make(map[leaderboardKey]map[*LeaderboardClient]bool),
+	last:    make(map[leaderboardKey]model.Scoreboard),
+	syncMu:  make(map[string]*sync.Mutex),
+}
+
+// contestLock returns the lock serializing scoreboard updates for a contest
+func (m *LeaderboardManager) contestLock(contestID string) *sync.Mutex {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	lock, ok := m.syncMu[contestID]
+	if !ok {
+		lock = &sync.Mutex{}
+		m.syncMu[contestID] = lock
+	}
+	return lock
+}
+
+// Subscribe sends a snapshot of the scoreboard to w and registers it for deltas
+func (m *LeaderboardManager) Subscribe(contestID string, live bool, w http.ResponseWriter, load ScoreboardLoader) (*LeaderboardClient, error) {
+	lock := m.contestLock(contestID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	board, err := load(live)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &LeaderboardClient{
+		ContestID:      contestID,
+		Live:           live,
+		ResponseWriter: w,
+		Done:           make(chan bool),
+	}
+	if err := client.send(LeaderboardUpdate{Type: "snapshot", ContestID: contestID, Scoreboard: &board}); err != nil {
+		return nil, err
+	}
+
+	key := leaderboardKey{contestID, live}
+	m.mu.Lock()
+	if m.clients[key] == nil {
+		m.clients[key] = make(map[*LeaderboardClient]bool)
+	}
+	m.clients[key][client] = true
+	m.last[key] = board
+	m.mu.Unlock()
+
+	log.Printf("Added leaderboard SSE client for contest %s (live %t)", contestID, live)
+
+	return client, nil
+}
+
+// Unsubscribe removes a leaderboard stream
+func (m *LeaderboardManager) Unsubscribe(client *LeaderboardClient) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := leaderboardKey{client.ContestID, client.Live}
+	if !m.clients[key][client] {
+		return
+	}
+
+	close(client.Done)
+	delete(m.clients[key], client)
+	if len(m.clients[key]) == 0 {
+		delete(m.clients, key)
+		delete(m.last, key)
+	}
+
+	log.Printf("Removed leaderboard SSE client for contest %s (live %t)", client.ContestID, client.Live)
+}
+
+// Publish recomputes each subscribed view of a contest's scoreboard and sends
+// subscribers what changed
+func (m *LeaderboardManager) Publish(contestID string, load ScoreboardLoader) {
+	lock := m.contestLock(contestID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	for _, live := range []bool{false, true} {
+		key := leaderboardKey{contestID, live}
+
+		m.mu.RLock()
+		subscribed := len(m.clients[key]) > 0
+		previous := m.last[key]
+		m.mu.RUnlock()
+		if !subscribed {
+			continue
+		}
+
+		board, err := load(live)
+		if err != nil {
+			log.Printf("Failed to compute leaderboard for contest %s: %v", contestID, err)
+			continue
+		}
+
+		update, changed := diffScoreboards(contestID, previous, board)
+		if !changed {
+			continue
+		}
+
+		m.mu.Lock()
+		m.last[key] = board
+		clients := make([]*LeaderboardClient, 0, len(m.clients[key]))
+		for client := range m.clients[key] {
+			clients = append(clients, client)
+		}
+		m.mu.Unlock()
+
+		for _, client := range clients {
+			if err := client.send(update); err != nil {
+				log.Printf("Failed to send leaderboard update for contest %s: %v", contestID, err)
+				m.Unsubscribe(client)
+			}
+		}
+	}
+}
+
+// diffScoreboards builds the update that turns previous into next. A change in
+// the problem set or in the freeze sends a full snapshot instead of a delta.
+func diffScoreboards(contestID string, previous, next model.Scoreboard) (LeaderboardUpdate, bool) {
+	if previous.Frozen != next.Frozen || previous.ScoringMode != next.ScoringMode || !reflect.DeepEqual(previous.Problems, next.Problems) {
+		return LeaderboardUpdate{Type: "snapshot", ContestID: contestID, Scoreboard: &next}, true
+	}
+
+	before := make(map[uuid.UUID]model.LeaderboardEntry, len(previous.Entries))
+	for _, entry := range previous.Entries {
+		before[entry.UserID] = entry
+	}
+
+	update := LeaderboardUpdate{Type: "delta", ContestID: contestID}
+	for _, entry := range next.Entries {
+		if old, ok := before[entry.UserID]; !ok || !reflect.DeepEqual(old, entry) {
+			update.Changed = append(update.Changed, entry)
+		}
+		delete(before, entry.UserID)
+	}
+	for userID := range before {
+		update.Removed = append(update.Removed, userID)
+	}
+
+	return update, len(update.Changed) > 0 || len(update.Removed) > 0
+}
+
+// send writes an update to the stream
+func (client *LeaderboardClient) send(update LeaderboardUpdate) error {
+	data, err := json.Marshal(update)
+	if err != nil {
+		return fmt.Errorf("failed to marshal update: %v", err)
+	}
+
+	return client.write(fmt.Sprintf("data: %s\n\n", data))
+}
+
+// write writes raw SSE lines to the stream and flushes them
+func (client *LeaderboardClient) write(message string) error {
+	client.writeMu.Lock()
+	defer client.writeMu.Unlock()
+
+	if client.closed {
+		return nil
+	}
+
+	if _, err := client.ResponseWriter.Write([]byte(message)); err != nil {
+		return fmt.Errorf("failed to write SSE message: %v", err)
+	}
+
+	if flusher, ok := client.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+
+	return nil
+}
+
+// close marks the stream finished. It waits for any write in progress, so once
+// it returns nothing touches the ResponseWriter again.
+func (client *LeaderboardClient) close() {
+	client.writeMu.Lock()
+	defer client.writeMu.Unlock()
+
+	client.closed = true
+}
+
+// HandleLeaderboardConnection streams a contest's scoreboard: a snapshot first,
+// then deltas whenever the standings change
+func HandleLeaderboardConnection(c echo.Context, contestID string, live bool, load ScoreboardLoader) error {
+	// Set SSE headers
+	h := c.Response().Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("Access-Control-Allow-Origin", "*")
+	h.Set("Access-Control-Allow-Headers", "Cache-Control")
+
+	client, err := GlobalLeaderboardManager.Subscribe(contestID, live, c.Response().Writer, load)
+	if err != nil {
+		log.Printf("Failed to start leaderboard stream for contest %s: %v", contestID, err)
+		return err
+	}
+
+	// Publish may still hold this client after Unsubscribe, so close it before
+	// the handler returns and the writer goes away
+	defer client.close()
+
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := client.write(": heartbeat\n\n"); err != nil {
+				GlobalLeaderboardManager.Unsubscribe(client)
+				return nil
+			}
+		case <-client.Done:
+			return nil
+		case <-c.Request().Context().Done():
+			GlobalLeaderboardManager.Unsubscribe(client)
+			return nil
+		}
+	}
+}
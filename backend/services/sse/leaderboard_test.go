@@ -0,0 +1,167 @@
+package sse
+
+import (
+	model "OJ-backend/models"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// newLeaderboardManager returns a manager without subscribers
+func newLeaderboardManager() *LeaderboardManager {
+	return &LeaderboardManager{
+		clients: make(map[leaderboardKey]map[*LeaderboardClient]bool),
+		last:    make(map[leaderboardKey]model.Scoreboard),
+		syncMu:  make(map[string]*sync.Mutex),
+	}
+}
+
+// updatesOf decodes the updates written to a stream
+func updatesOf(t *testing.T, rec *httptest.ResponseRecorder) []LeaderboardUpdate {
+	t.Helper()
+	updates := []LeaderboardUpdate{}
+	for _, message := range strings.Split(rec.Body.String(), "\n\n") {
+		data, ok := strings.CutPrefix(message, "data: ")
+		if !ok {
+			continue
+		}
+		var update LeaderboardUpdate
+		if err := json.Unmarshal([]byte(data), &update); err != nil {
+			t.Fatal(err)
+		}
+		updates = append(updates, update)
+	}
+	return updates
+}
+
+func TestDiffScoreboards(t *testing.T) {
+	alice, bob, carol := uuid.New(), uuid.New(), uuid.New()
+	problems := []model.ScoreboardProblem{{ID: uuid.New(), Label: "A"}}
+	board := func(entries ...model.LeaderboardEntry) model.Scoreboard {
+		return model.Scoreboard{ScoringMode: model.ScoringICPC, Problems: problems, Entries: entries}
+	}
+	entry := func(userID uuid.UUID, rank, solved int) model.LeaderboardEntry {
+		return model.LeaderboardEntry{UserID: userID, Rank: rank, Solved: solved}
+	}
+	previous := board(entry(alice, 1, 1), entry(bob, 2, 0), entry(carol, 2, 0))
+
+	frozen := previous
+	frozen.Frozen = true
+	moreProblems := previous
+	moreProblems.Problems = append([]model.ScoreboardProblem{}, problems...)
+	moreProblems.Problems = append(moreProblems.Problems, model.ScoreboardProblem{ID: uuid.New(), Label: "B"})
+
+	tests := []struct {
+		name     string
+		next     model.Scoreboard
+		changed  bool
+		kind     string
+		entries  []uuid.UUID
+		removals []uuid.UUID
+	}{
+		{name: "nothing changed", next: previous},
+		{
+			name:    "only the entries that moved",
+			next:    board(entry(bob, 1, 1), entry(alice, 1, 1), entry(carol, 3, 0)),
+			changed: true, kind: "delta", entries: []uuid.UUID{bob, carol},
+		},
+		{
+			name:    "a participant removed",
+			next:    board(entry(alice, 1, 1), entry(bob, 2, 0)),
+			changed: true, kind: "delta", removals: []uuid.UUID{carol},
+		},
+		{name: "the freeze starts", next: frozen, changed: true, kind: "snapshot"},
+		{name: "a problem added", next: moreProblems, changed: true, kind: "snapshot"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			update, changed := diffScoreboards("contest", previous, tt.next)
+			if changed != tt.changed {
+				t.Fatalf("changed = %v, want %v", changed, tt.changed)
+			}
+			if !changed {
+				return
+			}
+			if update.Type != tt.kind {
+				t.Fatalf("update type %q, want %q", update.Type, tt.kind)
+			}
+			if update.Type == "snapshot" {
+				if update.Scoreboard == nil || update.Scoreboard.Frozen != tt.next.Frozen || len(update.Scoreboard.Problems) != len(tt.next.Problems) {
+					t.Errorf("snapshot = %+v, want the next scoreboard", update.Scoreboard)
+				}
+				return
+			}
+
+			if len(update.Changed) != len(tt.entries) {
+				t.Fatalf("changed %d entries, want %d", len(update.Changed), len(tt.entries))
+			}
+			for i, userID := range tt.entries {
+				if update.Changed[i].UserID != userID {
+					t.Errorf("changed entry %d is %s, want %s", i, update.Changed[i].UserID, userID)
+				}
+			}
+			if len(update.Removed) != len(tt.removals) || (len(tt.removals) > 0 && update.Removed[0] != tt.removals[0]) {
+				t.Errorf("removed %v, want %v", update.Removed, tt.removals)
+			}
+		})
+	}
+}
+
+func TestLeaderboardPublish(t *testing.T) {
+	m := newLeaderboardManager()
+	alice := uuid.New()
+	solved := 0
+	load := func(live bool) (model.Scoreboard, error) {
+		board := model.Scoreboard{ScoringMode: model.ScoringICPC, Entries: []model.LeaderboardEntry{{UserID: alice, Rank: 1}}}
+		// The public view is frozen and does not show the solve
+		if live {
+			board.Entries[0].Solved = solved
+		}
+		return board, nil
+	}
+
+	public, live := httptest.NewRecorder(), httptest.NewRecorder()
+	if _, err := m.Subscribe("contest", false, public, load); err != nil {
+		t.Fatal(err)
+	}
+	liveClient, err := m.Subscribe("contest", true, live, load)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	solved = 1
+	m.Publish("contest", load)
+	// Nothing changed since the last update
+	m.Publish("contest", load)
+
+	if updates := updatesOf(t, public); len(updates) != 1 || updates[0].Type != "snapshot" {
+		t.Errorf("public stream got %+v, want only the snapshot", updates)
+	}
+	updates := updatesOf(t, live)
+	if len(updates) != 2 || updates[0].Type != "snapshot" || updates[1].Type != "delta" {
+		t.Fatalf("live stream got %+v, want a snapshot and one delta", updates)
+	}
+	if changed := updates[1].Changed; len(changed) != 1 || changed[0].Solved != 1 {
+		t.Errorf("delta changed %+v, want alice's solve", changed)
+	}
+
+	// A stream whose handler has returned is never written to again
+	liveClient.close()
+	m.Unsubscribe(liveClient)
+	solved = 2
+	m.Publish("contest", load)
+	if got := len(updatesOf(t, live)); got != 2 {
+		t.Errorf("closed live stream got %d updates, want 2", got)
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if _, ok := m.last[leaderboardKey{"contest", true}]; ok {
+		t.Error("kept the last live scoreboard after its only subscriber left")
+	}
+}
@@ -38,8 +38,12 @@ type SubmissionUpdate struct {
 	ExitCode      int    `json:"exit_code"`
 	Time          string `json:"time"`
 	Memory        string `json:"memory"`
+	CPUTimeMs     int    `json:"cpu_time_ms"`
+	WallTimeMs    int    `json:"wall_time_ms"`
+	MemoryKB      int    `json:"memory_kb"`
 	Message       string `json:"message"`
-	Status        string `json:"status"` // "completed", "error", etc.
+	State         string `json:"state"`  // Submission lifecycle status: queued, compiling, running, judged, cancelled or failed
+	Status        string `json:"status"` // "connected", "queued", "compiling", "running", "completed", etc.
 }
 
 var GlobalSSEManager *SSEManager
@@ -118,6 +122,22 @@ func (m *SSEManager) BroadcastToUser(userID, submissionID string, update Submiss
 	}
 }
 
+// PushToUser sends an intermediate update to a specific user's submission
+// without closing the connection
+func (m *SSEManager) PushToUser(userID, submissionID string, update SubmissionUpdate) {
+	m.clientsMux.RLock()
+	defer m.clientsMux.RUnlock()
+
+	if userClients, exists := m.clients[userID]; exists {
+		if client, exists := userClients[submissionID]; exists {
+			if err := m.sendSSEMessage(client, update); err != nil {
+				log.Printf("Failed to send SSE message to user %s, submission %s: %v", userID, submissionID, err)
+				go m.RemoveClient(userID, submissionID)
+			}
+		}
+	}
+}
+
 // sendSSEMessage sends a formatted SSE message to a client
 func (m *SSEManager) sendSSEMessage(client *SSEClient, update SubmissionUpdate) error {
 	data, err := json.Marshal(update)
@@ -162,11 +182,9 @@ func (m *SSEManager) cleanupExpiredConnections() {
 	}
 }
 
-// HandleSSEConnection handles incoming SSE connection requests
-func HandleSSEConnection(c echo.Context) error {
-	userID := c.Param("user_id")
-	submissionID := c.Param("submission_id")
-
+// HandleSSEConnection streams updates for a submission to its owner. Callers
+// must have checked that userID owns submissionID.
+func HandleSSEConnection(c echo.Context, userID, submissionID string) error {
 	if userID == "" || submissionID == "" {
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": "user_id and submission_id are required"})
 	}
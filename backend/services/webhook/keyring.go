@@ -0,0 +1,61 @@
+package webhook
+
+import (
+	"OJ-backend/config"
+	"log"
+	"strings"
+	"sync"
+)
+
+// DefaultKeyID names the legacy WEBHOOK_SECRET and is assumed when a
+// signature does not carry a key ID
+const DefaultKeyID = "default"
+
+var (
+	keys     map[string]string
+	keysOnce sync.Once
+)
+
+// loadKeys builds the keyring from the environment.
+//
+// WEBHOOK_SECRETS holds comma separated "<key id>:<secret>" pairs, so several
+// keys can be active while workers are rotated and a single worker's key can
+// be revoked by removing it. WEBHOOK_SECRET, if set, is added as DefaultKeyID.
+func loadKeys() {
+	keys = make(map[string]string)
+
+	if secret := config.GetEnv("WEBHOOK_SECRET"); secret != "" {
+		keys[DefaultKeyID] = secret
+	}
+
+	for _, entry := range strings.Split(config.GetEnv("WEBHOOK_SECRETS"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		keyID, secret, ok := strings.Cut(entry, ":")
+		if !ok || keyID == "" || secret == "" {
+			log.Printf("Ignoring malformed WEBHOOK_SECRETS entry")
+			continue
+		}
+		keys[keyID] = secret
+	}
+
+	log.Printf("Loaded %d webhook keys", len(keys))
+}
+
+// Secret returns the active secret for a key ID
+func Secret(keyID string) (string, bool) {
+	keysOnce.Do(loadKeys)
+
+	secret, ok := keys[keyID]
+	return secret, ok
+}
+
+// Configured reports whether any webhook key is active
+func Configured() bool {
+	keysOnce.Do(loadKeys)
+
+	return len(keys) > 0
+}
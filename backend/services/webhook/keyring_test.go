@@ -0,0 +1,53 @@
+package webhook
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLoadKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		secret  string
+		secrets string
+		want    map[string]string
+	}{
+		{
+			name: "nothing configured",
+			want: map[string]string{},
+		},
+		{
+			name:   "legacy secret only",
+			secret: "legacy",
+			want:   map[string]string{DefaultKeyID: "legacy"},
+		},
+		{
+			name:    "rotated keys alongside the legacy secret",
+			secret:  "legacy",
+			secrets: "worker-1:s3cret, worker-2:an0ther",
+			want:    map[string]string{DefaultKeyID: "legacy", "worker-1": "s3cret", "worker-2": "an0ther"},
+		},
+		{
+			name:    "secrets may contain colons",
+			secrets: "worker-1:a:b",
+			want:    map[string]string{"worker-1": "a:b"},
+		},
+		{
+			name:    "malformed and empty entries are skipped",
+			secrets: "worker-1,:nokey,worker-2:,,worker-3:ok",
+			want:    map[string]string{"worker-3": "ok"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WEBHOOK_SECRET", tt.secret)
+			t.Setenv("WEBHOOK_SECRETS", tt.secrets)
+
+			loadKeys()
+			if !reflect.DeepEqual(keys, tt.want) {
+				t.Errorf("keys = %v, want %v", keys, tt.want)
+			}
+		})
+	}
+}
@@ -9,8 +9,15 @@ import { fetchProblemByProblemId } from "@/fetch/problem";
 import { getServerSession } from "next-auth";
 import React from "react";
 
-const page = async ({ params }: { params: Promise<{ id: string }> }) => {
+const page = async ({
+  params,
+  searchParams,
+}: {
+  params: Promise<{ id: string }>;
+  searchParams: Promise<{ contest?: string }>;
+}) => {
   const { id } = await params;
+  const { contest } = await searchParams;
   const session: CustomSession | null = await getServerSession(authOptions);
   if (!session || !session.user) {
     return <div>Please log in to access this page.</div>;
@@ -22,7 +29,11 @@ const page = async ({ params }: { params: Promise<{ id: string }> }) => {
       <Navbar user={session?.user} />
       <div className="flex w-full p-4 gap-2">
         <ProblemDesc problem={problem!} />
-        <CodeEditor user={session?.user} problem={problem!} />
+        <CodeEditor
+          user={session?.user}
+          problem={problem!}
+          contestId={contest}
+        />
       </div>
     </>
   );
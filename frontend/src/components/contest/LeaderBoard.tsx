@@ -9,7 +9,7 @@ import {
   TableHeader,
   TableRow,
 } from "@/components/ui/table";
-import { fetchLeaderboard } from "@/fetch/leaderboard";
+import { fetchLeaderboard, subscribeToLeaderboard } from "@/fetch/leaderboard";
 import { Trophy } from "lucide-react";
 
 const LeaderBoard = ({
@@ -22,32 +22,35 @@ const LeaderBoard = ({
   const [leaderboardData, setLeaderboardData] = useState<
     LeaderboardEntryType[]
   >([]);
-
-  const POLL_INTERVAL = 15 * 60 * 1000; // 15 minutes
+  const [scoringMode, setScoringMode] = useState("icpc");
+  const [frozen, setFrozen] = useState(false);
 
   useEffect(() => {
     let isMounted = true;
 
-    const fetchData = async () => {
-      try {
-        const data = await fetchLeaderboard(contestId, token);
-        if (isMounted) {
-          setLeaderboardData(data);
-        }
-      } catch (err) {
-        if (isMounted) {
-          console.error("Failed to fetch leaderboard:", err);
-        }
-      }
+    const applyScoreboard = (data: ScoreboardType | null) => {
+      if (!isMounted) return;
+      setLeaderboardData(data?.entries ?? []);
+      setScoringMode(data?.scoring_mode ?? "icpc");
+      setFrozen(data?.frozen ?? false);
     };
 
-    fetchData();
-
-    const intervalId = setInterval(fetchData, POLL_INTERVAL);
+    // The stream starts with a snapshot and then sends changes as results come in
+    const eventSource = subscribeToLeaderboard(
+      contestId,
+      token,
+      applyScoreboard,
+      () => {
+        // EventSource reconnects on its own; show the latest standings meanwhile
+        fetchLeaderboard(contestId, token)
+          .then(applyScoreboard)
+          .catch((err) => console.error("Failed to fetch leaderboard:", err));
+      }
+    );
 
     return () => {
       isMounted = false;
-      clearInterval(intervalId);
+      eventSource.close();
     };
   }, [contestId, token]);
 
@@ -69,7 +72,11 @@ const LeaderBoard = ({
         <Trophy className="h-5 w-5 text-blue-500" />
         <h2 className="text-lg font-bold">Live Rankings</h2>
       </div>
-      <p className="text-sm text-gray-500 mb-4">Top performers this contest</p>
+      <p className="text-sm text-gray-500 mb-4">
+        {frozen
+          ? "Scoreboard is frozen, later attempts are shown as pending"
+          : "Top performers this contest"}
+      </p>
 
       <Table>
         <TableCaption className="sr-only">Leaderboard</TableCaption>
@@ -77,15 +84,29 @@ const LeaderBoard = ({
           <TableRow className="">
             <TableHead className="w-[60px]">#</TableHead>
             <TableHead>Username</TableHead>
-            <TableHead>Score</TableHead>
+            {scoringMode !== "icpc" ? (
+              <TableHead>Score</TableHead>
+            ) : (
+              <>
+                <TableHead>Solved</TableHead>
+                <TableHead>Penalty</TableHead>
+              </>
+            )}
           </TableRow>
         </TableHeader>
         <TableBody>
-          {leaderboardData.map((entry, index) => (
+          {leaderboardData.map((entry) => (
             <TableRow key={entry.user_id} className="border-b">
-              <TableCell className="font-medium">{index + 1}</TableCell>
+              <TableCell className="font-medium">{entry.rank}</TableCell>
               <TableCell>{entry.username}</TableCell>
-              <TableCell>{entry.total_score}</TableCell>
+              {scoringMode !== "icpc" ? (
+                <TableCell>{entry.total_score}</TableCell>
+              ) : (
+                <>
+                  <TableCell>{entry.solved}</TableCell>
+                  <TableCell>{entry.penalty}</TableCell>
+                </>
+              )}
             </TableRow>
           ))}
         </TableBody>
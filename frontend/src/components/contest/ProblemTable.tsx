@@ -48,11 +48,15 @@ const ProblemTable = ({ problems }: { problems: Array<ProblemType> }) => {
         <TableBody>
           {problems.map((problem, index) => (
             <TableRow key={problem.id} className="border-b">
-              <TableCell>{index + 1}</TableCell>
+              <TableCell>{problem.label ?? index + 1}</TableCell>
               <TableCell className="font-medium">{problem.title}</TableCell>
               <TableCell>
                 <Link
-                  href={`/contest/problem/${problem.id}`}
+                  href={
+                    problem.contest_id
+                      ? `/contest/problem/${problem.id}?contest=${problem.contest_id}`
+                      : `/contest/problem/${problem.id}`
+                  }
                   className="text-blue-600 hover:underline"
                 >
                   Solve
@@ -37,9 +37,12 @@ interface SubmissionResult {
 const CodeEditor = ({
   user,
   problem,
+  contestId,
 }: {
   user: CustomUser;
   problem: ProblemType;
+  // Omitted when practising the problem from the archive
+  contestId?: string;
 }) => {
   const [language, setLanguage] = useState("c_cpp");
   const [code, setCode] = useState("");
@@ -70,10 +73,10 @@ const CodeEditor = ({
       // Submit the code and get submission details
       const submission = await submitCode(
         problem.id,
-        user.id!,
         user.token!,
         code,
-        language
+        language,
+        contestId
       );
 
       console.log("Submission created:", submission);
@@ -81,7 +84,6 @@ const CodeEditor = ({
 
       // Subscribe to real-time updates
       eventSourceRef.current = subscribeToSubmissionUpdates(
-        user.id!,
         submission.id,
         user.token!,
         (update: SubmissionUpdate) => {
@@ -55,13 +55,92 @@ export const deleteContest = async (
   }
 };
 
-export const fetchContests = async (): Promise<ContestType[]> => {
+export interface ContestFilters {
+  status?: "upcoming" | "running" | "ended";
+  sort?: string;
+  page?: number;
+  per_page?: number;
+}
+
+export const fetchContests = async (
+  filters: ContestFilters = { per_page: 100 }
+): Promise<ContestType[]> => {
   try {
-    const response = await axios.get(`${BASE_URL}/contests`);
-    const contests = response.data;
+    const response = await axios.get(`${BASE_URL}/contests`, {
+      params: filters,
+    });
+    const contests = response.data?.contests;
     return contests ?? [];
   } catch (error) {
     console.error("Fetch contests error:", error);
     return [];
   }
 };
+
+export const registerForContest = async (
+  token: string,
+  contestId: string,
+  inviteCode?: string
+): Promise<boolean> => {
+  if (!token) {
+    console.error("User token is not available");
+    return false;
+  }
+  try {
+    await axios.post(
+      `${API_URL}/contest/${contestId}/register`,
+      { invite_code: inviteCode ?? "" },
+      {
+        headers: {
+          Authorization: `Bearer ${token}`,
+        },
+      }
+    );
+    return true;
+  } catch (error) {
+    console.error("Register for contest error:", error);
+    return false;
+  }
+};
+
+export const leaveContest = async (
+  token: string,
+  contestId: string
+): Promise<boolean> => {
+  if (!token) {
+    console.error("User token is not available");
+    return false;
+  }
+  try {
+    await axios.delete(`${API_URL}/contest/${contestId}/register`, {
+      headers: {
+        Authorization: `Bearer ${token}`,
+      },
+    });
+    return true;
+  } catch (error) {
+    console.error("Leave contest error:", error);
+    return false;
+  }
+};
+
+export const fetchContestDetail = async (
+  token: string,
+  contestId: string
+): Promise<ContestDetailType | null> => {
+  if (!token) {
+    console.error("User token is not available");
+    return null;
+  }
+  try {
+    const response = await axios.get(`${API_URL}/contest/${contestId}`, {
+      headers: {
+        Authorization: `Bearer ${token}`,
+      },
+    });
+    return response.data;
+  } catch (error) {
+    console.error("Fetch contest detail error:", error);
+    return null;
+  }
+};
@@ -4,18 +4,77 @@ import axios from "axios";
 export const fetchLeaderboard = async (
   contestId: string,
   token: string
-): Promise<LeaderboardEntryType[]> => {
-  if (!contestId || !token) return [];
+): Promise<ScoreboardType | null> => {
+  if (!contestId || !token) return null;
   try {
     const response = await axios.get(`${API_URL}/leaderboard/${contestId}`, {
       headers: {
         Authorization: `Bearer ${token}`,
       },
     });
-    const leaderboardData = response.data;
-    return leaderboardData ?? [];
+    const scoreboard = response.data;
+    return scoreboard ?? null;
   } catch (error) {
     console.error("Fetch leaderboard error:", error);
-    return [];
+    return null;
   }
 };
+
+type LeaderboardUpdate = {
+  type: "snapshot" | "delta";
+  contest_id: string;
+  scoreboard?: ScoreboardType;
+  changed?: LeaderboardEntryType[];
+  removed?: string[];
+};
+
+export const subscribeToLeaderboard = (
+  contestId: string,
+  token: string,
+  onUpdate: (scoreboard: ScoreboardType) => void,
+  onError?: (error: Error) => void
+): EventSource => {
+  // EventSource cannot send an Authorization header, so pass the token in the query
+  const eventSource = new EventSource(
+    `${API_URL}/leaderboard/${contestId}/events?token=${encodeURIComponent(token)}`
+  );
+
+  let scoreboard: ScoreboardType | null = null;
+
+  eventSource.onmessage = (event) => {
+    try {
+      const update: LeaderboardUpdate = JSON.parse(event.data);
+
+      if (update.type === "snapshot" && update.scoreboard) {
+        scoreboard = update.scoreboard;
+      } else if (update.type === "delta" && scoreboard) {
+        // Deltas carry every entry whose rank or results changed
+        const removed = new Set([
+          ...(update.removed ?? []),
+          ...(update.changed ?? []).map((entry) => entry.user_id),
+        ]);
+        const entries = scoreboard.entries
+          .filter((entry) => !removed.has(entry.user_id))
+          .concat(update.changed ?? []);
+        entries.sort(
+          (a, b) => a.rank - b.rank || a.username.localeCompare(b.username)
+        );
+        scoreboard = { ...scoreboard, entries };
+      } else {
+        return;
+      }
+
+      onUpdate(scoreboard);
+    } catch (error) {
+      console.error("Error parsing leaderboard update:", error);
+      onError?.(new Error("Failed to parse leaderboard update"));
+    }
+  };
+
+  eventSource.onerror = (event) => {
+    console.error("Leaderboard SSE connection error:", event);
+    onError?.(new Error("Connection to leaderboard updates failed"));
+  };
+
+  return eventSource;
+};
@@ -118,3 +118,53 @@ export const fetchProblemByProblemId = async (
     return null;
   }
 };
+
+export interface ArchiveFilters {
+  q?: string;
+  tag?: string;
+  difficulty_min?: number;
+  difficulty_max?: number;
+  sort?: string;
+  page?: number;
+  per_page?: number;
+}
+
+export interface ArchivePage {
+  problems: ProblemSummaryType[];
+  page: number;
+  per_page: number;
+  total: number;
+}
+
+export const fetchArchiveProblems = async (
+  token: string,
+  filters: ArchiveFilters = {}
+): Promise<ArchivePage | null> => {
+  if (!token) return null;
+  try {
+    const response = await axios.get(`${API_URL}/archive`, {
+      params: filters,
+      headers: {
+        Authorization: `Bearer ${token}`,
+      },
+    });
+    return response.data;
+  } catch (error) {
+    console.error("Fetch archive error:", error);
+    return null;
+  }
+};
+
+export const fetchTags = async (token: string): Promise<TagType[]> => {
+  try {
+    const response = await axios.get(`${API_URL}/tags`, {
+      headers: {
+        Authorization: `Bearer ${token}`,
+      },
+    });
+    return response.data ?? [];
+  } catch (error) {
+    console.error("Fetch tags error:", error);
+    return [];
+  }
+};
@@ -1,17 +1,57 @@
-import { API_URL, BASE_URL } from "@/lib/apiEndpoints";
+import { API_URL } from "@/lib/apiEndpoints";
 import axios from "axios";
 
 export interface SubmissionResponse {
   id: string;
   problem_id: string;
   user_id: string;
-  contest_id: string;
+  contest_id: string | null;
   submitted_at: string;
+  status: string;
   result: string;
   language: string;
   source_code: string;
   score: number;
   callback_url: string;
+  cpu_time_ms: number;
+  wall_time_ms: number;
+  memory_kb: number;
+}
+
+export interface SubmissionSummary {
+  id: string;
+  problem_id: string;
+  problem_title: string;
+  user_id: string;
+  username: string;
+  contest_id: string | null;
+  submitted_at: string;
+  status: string;
+  result: string;
+  language: string;
+  score: number;
+  cpu_time_ms: number;
+  memory_kb: number;
+  is_practice: boolean;
+}
+
+export interface SubmissionPage {
+  submissions: SubmissionSummary[];
+  page: number;
+  per_page: number;
+  total: number;
+}
+
+export interface SubmissionFilters {
+  contest_id?: string;
+  problem_id?: string;
+  language?: string;
+  result?: string;
+  status?: string;
+  from?: string;
+  to?: string;
+  page?: number;
+  per_page?: number;
 }
 
 export interface SubmissionUpdate {
@@ -25,16 +65,20 @@ export interface SubmissionUpdate {
   exit_code: number;
   time: string;
   memory: string;
+  cpu_time_ms: number;
+  wall_time_ms: number;
+  memory_kb: number;
   message: string;
+  state: string;
   status: string;
 }
 
 export const submitCode = async (
   problemId: string,
-  userId: string,
   token: string,
   code: string,
-  language: string
+  language: string,
+  contestId?: string
 ): Promise<SubmissionResponse> => {
   try {
     const payload = {
@@ -42,9 +86,10 @@ export const submitCode = async (
       result: "pending",
       language: language,
       score: 0,
+      contest_id: contestId,
     };
     const response = await axios.post(
-      `${API_URL}/submit/${userId}/${problemId}`,
+      `${API_URL}/submit/${problemId}`,
       payload,
       {
         headers: {
@@ -61,15 +106,15 @@ export const submitCode = async (
 };
 
 export const subscribeToSubmissionUpdates = (
-  userId: string,
   submissionId: string,
   token: string,
   onUpdate: (update: SubmissionUpdate) => void,
   onError?: (error: Error) => void,
   onComplete?: () => void
 ): EventSource => {
+  // EventSource cannot send an Authorization header, so pass the token in the query
   const eventSource = new EventSource(
-    `${BASE_URL}/submission/${userId}/${submissionId}/events`,
+    `${API_URL}/submission/${submissionId}/events?token=${encodeURIComponent(token)}`,
     {
       withCredentials: false,
     }
@@ -115,3 +160,22 @@ export const subscribeToSubmissionUpdates = (
 
   return eventSource;
 };
+
+export const fetchMySubmissions = async (
+  token: string,
+  filters: SubmissionFilters = {}
+): Promise<SubmissionPage | null> => {
+  if (!token) return null;
+  try {
+    const response = await axios.get(`${API_URL}/submissions`, {
+      params: filters,
+      headers: {
+        Authorization: `Bearer ${token}`,
+      },
+    });
+    return response.data;
+  } catch (error) {
+    console.error("Fetch submissions error:", error);
+    return null;
+  }
+};
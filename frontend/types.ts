@@ -5,15 +5,52 @@ type ContestType = {
   start_time: Date;
   end_time: Date;
   created_at: Date;
+  registration_deadline?: Date | null;
+  max_participants: number;
+  is_private: boolean;
+  invite_code?: string;
+  status: "upcoming" | "running" | "ended";
+};
+
+type ContestDetailType = ContestType & {
+  server_time: Date;
+  registration_status: "" | "registered" | "pending" | "disqualified";
+  problems: {
+    id: string;
+    label: string;
+    title: string;
+    points: number;
+    color: string;
+    attempted: boolean;
+    solved: boolean;
+  }[];
 };
 
 type ProblemType = {
   id: string;
   title: string;
   description: string;
-  contest_id: string;
+  is_public: boolean;
+  difficulty: number;
+  source: string;
   created_at: Date;
+  tags?: TagType[];
   tests?: TestcaseType[];
+  // Set when the problem is listed as part of a contest
+  contest_id?: string;
+  label?: string;
+  order_index?: number;
+  points?: number;
+  color?: string;
+};
+
+type TagType = {
+  id: string;
+  name: string;
+};
+
+type ProblemSummaryType = ProblemType & {
+  solve_count: number;
 };
 
 type TestcaseType = {
@@ -21,11 +58,42 @@ type TestcaseType = {
   input: string;
   output: string;
   problem_id: string;
+  is_sample: boolean;
   created_at: Date;
 };
 
+type ProblemResultType = {
+  problem_id: string;
+  attempts: number;
+  solved: boolean;
+  solved_at_minutes: number;
+  penalty: number;
+  first_to_solve: boolean;
+  score: number;
+  pending: number;
+};
+
 type LeaderboardEntryType = {
+  rank: number;
   user_id: string;
   username: string;
+  solved: number;
+  penalty: number;
   total_score: number;
+  problems: ProblemResultType[];
+};
+
+type ScoreboardType = {
+  contest_id: string;
+  scoring_mode: string;
+  problems: {
+    id: string;
+    label: string;
+    title: string;
+    points: number;
+    color: string;
+  }[];
+  entries: LeaderboardEntryType[];
+  frozen: boolean;
+  frozen_at?: Date;
 };
@@ -3,6 +3,7 @@ package isolatejob
 import (
 	"OJ-Worker/schema"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -10,6 +11,7 @@ import (
 	"strconv"
 	"strings"
 	"sync/atomic"
+	"time"
 )
 
 const (
@@ -19,6 +21,12 @@ const (
 	MetadataFileName = "metadata.txt"
 )
 
+// cleanupTimeout bounds how long box cleanup may take once the job context is gone
+const cleanupTimeout = 10 * time.Second
+
+// ErrInterrupted is returned when the job context was cancelled before judging finished
+var ErrInterrupted = errors.New("isolate job interrupted")
+
 var boxIDCounter int64
 
 type IsolateJob struct {
@@ -33,50 +41,66 @@ type IsolateJob struct {
 	OutputFile string
 	ErrorFile  string
 	MetaFile   string
+	OnStatus   func(status string) // Called when the job starts compiling or running
 }
 
-func ProcessSubmission(submission *schema.RabbitMQPayload, response *schema.JudgeResponse, ctx context.Context) error {
+func ProcessSubmission(submission *schema.RabbitMQPayload, response *schema.JudgeResponse, ctx context.Context, onStatus func(status string)) error {
 
 	job := &IsolateJob{
 		Submission: submission,
 		BoxID:      int(atomic.AddInt64(&boxIDCounter, 1)) % 2147483647,
 		Response:   response,
+		OnStatus:   onStatus,
 	}
 
 	return job.Execute(ctx)
 }
 
 func (j *IsolateJob) Execute(ctx context.Context) error {
+	defer j.CleanUp(ctx)
+
 	if err := j.InitializeIsolate(ctx); err != nil {
+		if ctx.Err() != nil {
+			return ErrInterrupted
+		}
 		j.Response.Result = schema.ResultSystemError
-		j.CleanUp(ctx)
 		return fmt.Errorf("failed to initialize isolate: %v", err)
 	}
+	if j.Submission.CompileCmd != "" {
+		j.reportStatus(schema.StatusCompiling)
+	}
 	success, err := j.Compile(ctx)
+	// A killed compile looks like a compile error, so check for cancellation first
+	if ctx.Err() != nil {
+		return ErrInterrupted
+	}
 	if err != nil {
 		j.Response.Result = schema.ResultSystemError
-		j.CleanUp(ctx)
 		return fmt.Errorf("failed to compile: %v", err)
 	}
 	if !success {
-		j.CleanUp(ctx)
 		return nil
 	}
 
-	success, err = j.Run(ctx)
+	j.reportStatus(schema.StatusRunning)
+	_, err = j.Run(ctx)
+	// A killed run looks like a runtime error or wrong answer, so check for cancellation first
+	if ctx.Err() != nil {
+		return ErrInterrupted
+	}
 	if err != nil {
 		j.Response.Result = schema.ResultSystemError
-		j.CleanUp(ctx)
 		return fmt.Errorf("failed to run: %v", err)
 	}
-	if !success {
-		j.CleanUp(ctx)
-		return nil
-	}
 
-	j.CleanUp(ctx)
 	return nil
+}
 
+// reportStatus notifies the caller that the job entered a new stage
+func (j *IsolateJob) reportStatus(status string) {
+	if j.OnStatus != nil {
+		j.OnStatus(status)
+	}
 }
 
 func (j *IsolateJob) InitializeIsolate(ctx context.Context) error {
@@ -254,6 +278,7 @@ func (j *IsolateJob) Run(ctx context.Context) (bool, error) {
 	j.Response.ExitCode = metadata["exit-code"]
 	j.Response.ExitSignal = metadata["exit-signal"]
 	j.Response.Time = metadata["time"]
+	j.Response.WallTime = metadata["time-wall"]
 	j.Response.Memory = metadata["max-rss"]
 	fmt.Println("----------------Run Metadata------------")
 	fmt.Println(metadata)
@@ -318,8 +343,13 @@ func (j *IsolateJob) resetMetadata(ctx context.Context) error {
 	return nil
 }
 
+// CleanUp removes the isolate box. It still runs when ctx is cancelled so that
+// interrupted jobs do not leave boxes behind.
 func (j *IsolateJob) CleanUp(ctx context.Context) error {
-	cmd := exec.CommandContext(ctx, "isolate", "-b", strconv.Itoa(j.BoxID), "--cleanup")
+	cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(cleanupCtx, "isolate", "-b", strconv.Itoa(j.BoxID), "--cleanup")
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("failed to cleanup isolate box: %v", err)
 	}
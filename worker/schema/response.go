@@ -4,8 +4,9 @@ type JudgeResponse struct {
 	Stdin         string `json:"stdin"`
 	Stdout        string `json:"stdout"`
 	Stderr        string `json:"stderr"`
-	Time          string `json:"time"`
-	Memory        string `json:"memory"`
+	Time          string `json:"time"`      // CPU time in seconds as reported by isolate
+	WallTime      string `json:"wall_time"` // Wall clock time in seconds as reported by isolate
+	Memory        string `json:"memory"`    // Peak resident memory in KB as reported by isolate
 	ExitSignal    string `json:"exit_signal"`
 	ExitCode      string `json:"exit_code"`
 	Message       string `json:"message"`
@@ -25,3 +26,11 @@ const (
 	ResultSystemError              = "SE"
 	ResultUnknownError             = "UE"
 )
+
+// Submission lifecycle statuses reported to the backend
+const (
+	StatusCompiling = "compiling"
+	StatusRunning   = "running"
+	StatusJudged    = "judged"
+	StatusFailed    = "failed"
+)
@@ -8,11 +8,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 // CallbackPayload represents the data sent back to the server
 type CallbackPayload struct {
 	SubmissionID  string `json:"submission_id"`
+	Status        string `json:"status"` // Lifecycle status: compiling, running, judged or failed
 	Result        string `json:"result"`
 	Score         int    `json:"score"`
 	StdOutput     string `json:"std_output"`
@@ -23,6 +29,37 @@ type CallbackPayload struct {
 	Time          string `json:"time"`
 	Memory        string `json:"memory"`
 	Message       string `json:"message"`
+	CPUTimeMs     int    `json:"cpu_time_ms"`  // CPU time in milliseconds
+	WallTimeMs    int    `json:"wall_time_ms"` // Wall clock time in milliseconds
+	MemoryKB      int    `json:"memory_kb"`    // Peak resident memory in KB
+}
+
+// WebhookKey is the secret this worker signs callbacks with. Each worker can
+// have its own key ID so that a single compromised worker can be revoked.
+type WebhookKey struct {
+	ID     string
+	Secret string
+}
+
+// LoadWebhookKey reads WEBHOOK_KEY_ID and WEBHOOK_SECRET. The key ID defaults
+// to "default", which the backend maps to its own WEBHOOK_SECRET.
+func LoadWebhookKey() (WebhookKey, error) {
+	key := WebhookKey{
+		ID:     GetEnv("WEBHOOK_KEY_ID"),
+		Secret: GetEnv("WEBHOOK_SECRET"),
+	}
+	if key.ID == "" {
+		key.ID = "default"
+	}
+
+	if key.Secret == "" {
+		return key, fmt.Errorf("WEBHOOK_SECRET is not set")
+	}
+	if strings.ContainsAny(key.ID, ",=:") {
+		return key, fmt.Errorf("WEBHOOK_KEY_ID must not contain ',', '=' or ':'")
+	}
+
+	return key, nil
 }
 
 // generateHMAC generates HMAC-SHA256 signature for the payload
@@ -32,16 +69,26 @@ func generateHMAC(payload []byte, secret string) string {
 	return hex.EncodeToString(h.Sum(nil))
 }
 
+// signedContent binds the timestamp and delivery ID to the payload so neither
+// can be swapped on a captured request: "<timestamp>.<delivery id>.<body>"
+func signedContent(timestamp, deliveryID string, payload []byte) []byte {
+	content := []byte(timestamp + "." + deliveryID + ".")
+	return append(content, payload...)
+}
+
 // SendCallback sends HMAC-authenticated callback to the server
-func SendCallback(callbackURL string, payload CallbackPayload, webhookSecret string) error {
+func SendCallback(callbackURL string, payload CallbackPayload, key WebhookKey) error {
 	// Marshal payload to JSON
 	jsonPayload, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("failed to marshal payload: %v", err)
 	}
 
-	// Generate HMAC signature
-	signature := generateHMAC(jsonPayload, webhookSecret)
+	// Generate HMAC signature over a fresh timestamp and a unique delivery ID
+	// so the backend can reject stale and replayed callbacks
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	deliveryID := uuid.New().String()
+	signature := generateHMAC(signedContent(timestamp, deliveryID, jsonPayload), key.Secret)
 
 	// Create HTTP request
 	req, err := http.NewRequest("POST", callbackURL, bytes.NewBuffer(jsonPayload))
@@ -51,7 +98,9 @@ func SendCallback(callbackURL string, payload CallbackPayload, webhookSecret str
 
 	// Set headers
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-OJ-Signature", fmt.Sprintf("sha256=%s", signature))
+	req.Header.Set("X-OJ-Signature", fmt.Sprintf("keyid=%s,sha256=%s", key.ID, signature))
+	req.Header.Set("X-OJ-Timestamp", timestamp)
+	req.Header.Set("X-OJ-Delivery", deliveryID)
 	req.Header.Set("User-Agent", "OJ-Worker/1.0")
 
 	// Send request
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+func TestRunConsumerSharesSlots(t *testing.T) {
+	const numWorkers = 2
+
+	tests := []struct {
+		name        string
+		connections int
+	}{
+		{"one connection", 1},
+		// The consumers of a lost connection are still judging when the next one starts
+		{"reconnected while jobs are running", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slots := make(chan struct{}, numWorkers)
+			var running, peak, handled int32
+			handle := func(amqp091.Delivery) {
+				now := atomic.AddInt32(&running, 1)
+				for {
+					old := atomic.LoadInt32(&peak)
+					if now <= old || atomic.CompareAndSwapInt32(&peak, old, now) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				atomic.AddInt32(&handled, 1)
+			}
+
+			var wg sync.WaitGroup
+			for c := 0; c < tt.connections; c++ {
+				msgs := make(chan amqp091.Delivery, numWorkers*2)
+				for i := 0; i < cap(msgs); i++ {
+					msgs <- amqp091.Delivery{}
+				}
+				close(msgs)
+
+				for i := 0; i < numWorkers; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						runConsumer(context.Background(), msgs, slots, "worker", handle)
+					}()
+				}
+			}
+			wg.Wait()
+
+			if want := int32(tt.connections * numWorkers * 2); handled != want {
+				t.Errorf("handled %d messages, want %d", handled, want)
+			}
+			if peak > numWorkers {
+				t.Errorf("%d jobs ran at once, want at most %d", peak, numWorkers)
+			}
+		})
+	}
+}
+
+func TestRunConsumerStopsOnShutdown(t *testing.T) {
+	slots := make(chan struct{}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		runConsumer(ctx, make(chan amqp091.Delivery), slots, "worker", func(amqp091.Delivery) {
+			t.Error("handled a message after shutdown")
+		})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("consumer did not stop after shutdown")
+	}
+	if len(slots) != 0 {
+		t.Errorf("consumer kept %d slots after stopping", len(slots))
+	}
+}
+
+// acknowledger records how a delivery was settled
+type acknowledger struct {
+	acked, nacked, requeued bool
+}
+
+func (a *acknowledger) Ack(tag uint64, multiple bool) error {
+	a.acked = true
+	return nil
+}
+
+func (a *acknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	a.nacked, a.requeued = true, requeue
+	return nil
+}
+
+func (a *acknowledger) Reject(tag uint64, requeue bool) error {
+	return a.Nack(tag, false, requeue)
+}
+
+func TestProcessMessageSettlesDelivery(t *testing.T) {
+	shutDown, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		body     string
+		requeued bool
+	}{
+		// Another worker picks the job up again; no callback reports a failure
+		{"interrupted by shutdown", shutDown, `{"submission_id":"11111111-1111-1111-1111-111111111111","source_code":"int main() {}"}`, true},
+		{"malformed message", context.Background(), `{"submission_id":`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ack := &acknowledger{}
+			processMessage(tt.ctx, amqp091.Delivery{Acknowledger: ack, Body: []byte(tt.body)}, "worker")
+
+			if ack.acked || !ack.nacked || ack.requeued != tt.requeued {
+				t.Errorf("delivery settled as %+v, want a nack with requeue %v", *ack, tt.requeued)
+			}
+		})
+	}
+}